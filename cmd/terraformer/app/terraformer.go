@@ -8,6 +8,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -73,8 +74,25 @@ Also, it continuously updates the state ConfigMap by running a file watcher and
 
 	// setup a subcommand for every supported terraform command
 	for command := range terraformer.SupportedCommands {
-		addSubcommand(cmd, command, tfOpts)
+		switch command {
+		case terraformer.Drift, terraformer.Refresh, terraformer.Import, terraformer.StateRm, terraformer.StateMv:
+			// these commands have dedicated help texts or flags below
+			continue
+		}
+		addSubcommand(cmd, command, tfOpts,
+			fmt.Sprintf("execute `terraform %s`", command),
+			fmt.Sprintf("terraformer %s executes the terraform %s command with the given configuration", command, command),
+			"", nil,
+		)
 	}
+	addDriftSubcommand(cmd, tfOpts)
+	addRefreshSubcommand(cmd, tfOpts)
+	addImportSubcommand(cmd, tfOpts)
+	addStateOperationsSubcommand(cmd, terraformer.StateRm, tfOpts)
+	addStateOperationsSubcommand(cmd, terraformer.StateMv, tfOpts)
+	addRekeySubcommand(cmd, tfOpts)
+	addMigrateStateSubcommand(cmd, tfOpts)
+	addStateSubcommand(cmd, tfOpts)
 
 	// setup flags
 	tfOpts.AddFlags(cmd.PersistentFlags())
@@ -84,16 +102,21 @@ Also, it continuously updates the state ConfigMap by running a file watcher and
 	return cmd
 }
 
-func addSubcommand(cmd *cobra.Command, command terraformer.Command, opts *terraformercmd.Options) {
-	cmd.AddCommand(&cobra.Command{
-		Use:     string(command),
-		Short:   fmt.Sprintf("execute `terraform %s`", command),
-		Long:    fmt.Sprintf("terraformer %s executes the terraform %s command with the given configuration", command, command),
+// addSubcommand adds a subcommand executing the given terraform command. The subcommand is named after the command with
+// spaces replaced by dashes (e.g. `state-rm`), exampleFlags are appended to the generic example and addFlags (optional)
+// adds the flags specific to the command.
+func addSubcommand(cmd *cobra.Command, command terraformer.Command, opts *terraformercmd.Options, short, long, exampleFlags string, addFlags func(fs *pflag.FlagSet)) {
+	use := strings.ReplaceAll(string(command), " ", "-")
+
+	subcommand := &cobra.Command{
+		Use:     use,
+		Short:   short,
+		Long:    long,
 		Args:    cobra.NoArgs,
-		Example: exampleForCommand(string(command)),
+		Example: exampleForCommand(use) + exampleFlags,
 
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if err := opts.Complete(); err != nil {
+			if err := opts.CompleteForCommand(command); err != nil {
 				return err
 			}
 
@@ -109,9 +132,177 @@ func addSubcommand(cmd *cobra.Command, command terraformer.Command, opts *terraf
 
 			return tf.Run(command)
 		},
+	}
+	if addFlags != nil {
+		addFlags(subcommand.Flags())
+	}
+
+	cmd.AddCommand(subcommand)
+}
+
+func addDriftSubcommand(cmd *cobra.Command, opts *terraformercmd.Options) {
+	addSubcommand(cmd, terraformer.Drift, opts,
+		"detect resources changed outside of terraform",
+		`terraformer drift executes a refresh-only terraform plan, which doesn't modify the state, and stores a report of
+all resources changed outside of terraform in the ConfigMap given by --drift-report-configmap-name.
+It exits with exit code 12 if any drift was detected.`,
+		` \
+  --drift-report-configmap-name=example.infra.tf-drift`,
+		nil,
+	)
+}
+
+func addRefreshSubcommand(cmd *cobra.Command, opts *terraformercmd.Options) {
+	addSubcommand(cmd, terraformer.Refresh, opts,
+		"update the state to match the real infrastructure",
+		`terraformer refresh executes a refresh-only terraform apply, which updates the state with the current attributes
+of all resources (e.g. after they were fixed manually) without changing any infrastructure, and stores the refreshed state.`,
+		"",
+		nil,
+	)
+}
+
+func addImportSubcommand(cmd *cobra.Command, opts *terraformercmd.Options) {
+	addSubcommand(cmd, terraformer.Import, opts,
+		"import existing resources into the state",
+		`terraformer import executes terraform import for every address=id pair in the import list, which is read from the
+key given by --import-configmap-key of the ConfigMap given by --import-configmap-name. Resources, that are already in
+the state, are skipped. If an import fails, the remaining resources are imported anyway and terraformer fails afterwards.`,
+		` \
+  --import-configmap-name=example.infra.tf-imports`,
+		nil,
+	)
+}
+
+func addStateOperationsSubcommand(cmd *cobra.Command, command terraformer.Command, opts *terraformercmd.Options) {
+	var short, long, exampleFlags string
+
+	switch command {
+	case terraformer.StateRm:
+		short = "remove resources from the state"
+		long = `terraformer state-rm executes terraform state rm for all resource addresses given by --address and in the
+ConfigMap given by --operations-configmap-name (one address per line). The resources are only removed from the state,
+they are not destroyed.`
+		exampleFlags = ` \
+  --address=aws_vpc.main`
+	case terraformer.StateMv:
+		short = "move resources to different addresses in the state"
+		long = `terraformer state-mv executes terraform state mv for all source=destination pairs of resource addresses given by
+--move and in the ConfigMap given by --operations-configmap-name (one pair per line).`
+		exampleFlags = ` \
+  --move=aws_vpc.main=module.network.aws_vpc.main`
+	}
+
+	addSubcommand(cmd, command, opts, short, long+`
+The state is recorded in the state history before it is modified, which requires --state-history-limit to be set.`,
+		exampleFlags,
+		func(fs *pflag.FlagSet) {
+			opts.AddStateOperationsFlags(fs, command)
+		},
+	)
+}
+
+func addRekeySubcommand(cmd *cobra.Command, opts *terraformercmd.Options) {
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rekey",
+		Short: "re-encrypt the state with the active encryption key",
+		Long: `terraformer rekey decrypts the stored state and the revisions of the state history with any of the keys in
+--state-encryption-key-dir and stores them encrypted with the key given by --state-encryption-key-id. Use it for
+rotating the state encryption key.`,
+		Args: cobra.NoArgs,
+		Example: exampleForCommand("rekey") + ` \
+  --state-encryption-key-dir=/etc/terraformer/encryption-keys \
+  --state-encryption-key-id=key-2`,
+
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := opts.Complete(); err != nil {
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			tf, err := terraformer.NewDefaultTerraformer(opts.Completed())
+			if err != nil {
+				return err
+			}
+
+			return tf.RekeyState(cmd.Context())
+		},
 	})
 }
 
+func addMigrateStateSubcommand(cmd *cobra.Command, opts *terraformercmd.Options) {
+	cmd.AddCommand(&cobra.Command{
+		Use:   "migrate-state",
+		Short: "move the state from the state ConfigMap into the state Secret",
+		Long: `terraformer migrate-state copies the state from the ConfigMap given by --state-configmap-name into a Secret with
+the same name, verifies that the state can be read back from the Secret and deletes the ConfigMap afterwards.
+It requires --state-kind=Secret and does nothing if the ConfigMap doesn't exist.`,
+		Args: cobra.NoArgs,
+		Example: exampleForCommand("migrate-state") + ` \
+  --state-kind=Secret`,
+
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := opts.Complete(); err != nil {
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			tf, err := terraformer.NewDefaultTerraformer(opts.Completed())
+			if err != nil {
+				return err
+			}
+
+			return tf.MigrateState(cmd.Context())
+		},
+	})
+}
+
+func addStateSubcommand(cmd *cobra.Command, opts *terraformercmd.Options) {
+	stateCmd := &cobra.Command{
+		Use:   "state",
+		Short: "manage the stored state",
+		Args:  cobra.NoArgs,
+	}
+
+	var toSerial int64
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "restore a state revision from the state history",
+		Long: `terraformer state rollback restores the state revision with the serial given by --to-serial from the state history
+(see --state-history-limit) into the state ConfigMap. If there are multiple revisions with this serial, the most recent
+one is restored. The replaced state is recorded in the state history.`,
+		Args: cobra.NoArgs,
+		Example: exampleForCommand("state rollback") + ` \
+  --state-history-limit=5 \
+  --to-serial=12`,
+
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := opts.Complete(); err != nil {
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			tf, err := terraformer.NewDefaultTerraformer(opts.Completed())
+			if err != nil {
+				return err
+			}
+
+			return tf.RollbackState(cmd.Context(), toSerial)
+		},
+	}
+	rollbackCmd.Flags().Int64Var(&toSerial, "to-serial", 0, "Serial of the state revision to restore")
+	_ = rollbackCmd.MarkFlagRequired("to-serial")
+
+	stateCmd.AddCommand(rollbackCmd)
+	cmd.AddCommand(stateCmd)
+}
+
 // custom usage template:
 // - exclude `terraformer [flags]` if command is hidden (e.g. root command)
 // - indicate [flags] after `terraformer [command]` (for subcommands)
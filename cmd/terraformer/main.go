@@ -30,10 +30,10 @@ func main() {
 			fmt.Printf("error running terraformer: %v", err)
 		}
 
-		// set exit code from terraform
-		withExitCode := &utils.WithExitCode{}
-		if errors.As(err, withExitCode) {
-			if exitCode := withExitCode.ExitCode(); exitCode > 0 {
+		// set exit code from terraform or terraformer itself
+		var exitCoder utils.ExitCoder
+		if errors.As(err, &exitCoder) {
+			if exitCode := exitCoder.ExitCode(); exitCode > 0 {
 				os.Exit(exitCode)
 			}
 		}
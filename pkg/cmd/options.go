@@ -7,6 +7,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,15 +19,67 @@ import (
 
 // Options is a struct that holds options for the terraformer binary
 type Options struct {
-	configurationConfigMapName string
-	stateConfigMapName         string
-	variablesSecretName        string
+	configurationConfigMapName       string
+	extraConfigurationConfigMapNames []string
+	stateConfigMapName               string
+	variablesSecretName              string
+	variablesSources                 []string
+	variablesEnvSecretNames          []string
 
 	kubeconfig string
 	namespace  string
 
 	baseDir string
 
+	stateKind         string
+	stateChunkSize    int
+	stateHistoryLimit int
+	compressState     bool
+
+	stateEncryptionKeyDir string
+	stateEncryptionKeyID  string
+
+	leaseDuration    time.Duration
+	leaseWaitTimeout time.Duration
+	leasePolicy      string
+
+	stateUpdateDebounce   time.Duration
+	stateUpdateMaxDelay   time.Duration
+	stateFilePollInterval time.Duration
+
+	planSecretName       string
+	outputsConfigMapName string
+	outputsSecretName    string
+
+	driftReportConfigMapName string
+	importConfigMapName      string
+	importConfigMapKey       string
+
+	targets             []string
+	replace             []string
+	targetConfigMapName string
+
+	stateOperations              []string
+	stateOperationsConfigMapName string
+	stateOperationsConfigMapKey  string
+
+	parallelism int
+	lockTimeout time.Duration
+	skipRefresh bool
+	extraArgs   []string
+
+	providerLockConfigMapName string
+	strictProviderLock        bool
+
+	providerMigrations             []string
+	providerMigrationConfigMapName string
+
+	httpBackend      bool
+	forceStateUpdate bool
+
+	// command is the terraform command, that the options are completed for, if any.
+	command terraformer.Command
+
 	completed *terraformer.Config
 }
 
@@ -34,6 +88,13 @@ func NewOptions() *Options {
 	return &Options{}
 }
 
+// CompleteForCommand tries to complete the provided Options for executing the given terraform command, i.e. the
+// options required by the command are validated as well.
+func (o *Options) CompleteForCommand(command terraformer.Command) error {
+	o.command = command
+	return o.Complete()
+}
+
 // Complete tries to complete the provided Options
 func (o *Options) Complete() error {
 	o.addDefaults()
@@ -57,13 +118,61 @@ func (o *Options) Complete() error {
 		return err
 	}
 
+	var stateEncryptionKeys *terraformer.EncryptionKeys
+	if len(o.stateEncryptionKeyDir) > 0 {
+		stateEncryptionKeys, err = terraformer.LoadEncryptionKeys(o.stateEncryptionKeyDir, o.stateEncryptionKeyID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// extra arguments, variables sources and provider migrations were already validated
+	extraArgs, _ := parseExtraArgs(o.extraArgs)
+	variablesSources, _ := o.parseVariablesSources()
+	providerMigrations, _ := parseProviderMigrations(o.providerMigrations)
+
 	o.completed = &terraformer.Config{
-		ConfigurationConfigMapName: o.configurationConfigMapName,
-		StateConfigMapName:         o.stateConfigMapName,
-		VariablesSecretName:        o.variablesSecretName,
-		Namespace:                  namespace,
-		RESTConfig:                 restConfig,
-		BaseDir:                    o.baseDir,
+		ConfigurationConfigMapName:       o.configurationConfigMapName,
+		ExtraConfigurationConfigMapNames: o.extraConfigurationConfigMapNames,
+		StateConfigMapName:               o.stateConfigMapName,
+		StateKind:                        terraformer.StateKind(o.stateKind),
+		VariablesSecretName:              o.variablesSecretName,
+		VariablesSources:                 variablesSources,
+		Namespace:                        namespace,
+		RESTConfig:                       restConfig,
+		BaseDir:                          o.baseDir,
+		StateChunkSize:                   o.stateChunkSize,
+		StateHistoryLimit:                o.stateHistoryLimit,
+		CompressState:                    o.compressState,
+		StateEncryptionKeys:              stateEncryptionKeys,
+		LeaseDuration:                    o.leaseDuration,
+		LeaseWaitTimeout:                 o.leaseWaitTimeout,
+		LeasePolicy:                      terraformer.LeasePolicy(o.leasePolicy),
+		StateUpdateDebounce:              o.stateUpdateDebounce,
+		StateUpdateMaxDelay:              o.stateUpdateMaxDelay,
+		StateFilePollInterval:            o.stateFilePollInterval,
+		PlanSecretName:                   o.planSecretName,
+		OutputsConfigMapName:             o.outputsConfigMapName,
+		OutputsSecretName:                o.outputsSecretName,
+		DriftReportConfigMapName:         o.driftReportConfigMapName,
+		ImportConfigMapName:              o.importConfigMapName,
+		ImportConfigMapKey:               o.importConfigMapKey,
+		Targets:                          o.targets,
+		Replace:                          o.replace,
+		TargetConfigMapName:              o.targetConfigMapName,
+		StateOperations:                  o.stateOperations,
+		StateOperationsConfigMapName:     o.stateOperationsConfigMapName,
+		StateOperationsConfigMapKey:      o.stateOperationsConfigMapKey,
+		Parallelism:                      o.parallelism,
+		LockTimeout:                      o.lockTimeout,
+		SkipRefresh:                      o.skipRefresh,
+		ExtraArgs:                        extraArgs,
+		ProviderLockConfigMapName:        o.providerLockConfigMapName,
+		StrictProviderLock:               o.strictProviderLock,
+		ProviderMigrations:               providerMigrations,
+		ProviderMigrationConfigMapName:   o.providerMigrationConfigMapName,
+		HTTPBackend:                      o.httpBackend,
+		ForceStateUpdate:                 o.forceStateUpdate,
 	}
 
 	return nil
@@ -89,18 +198,201 @@ func (o *Options) validate() error {
 	if len(o.variablesSecretName) == 0 {
 		return fmt.Errorf("flag --variables-secret-name was not set")
 	}
+	if kind := terraformer.StateKind(o.stateKind); len(kind) > 0 && kind != terraformer.StateKindConfigMap && kind != terraformer.StateKindSecret {
+		return fmt.Errorf("flag --state-kind must be one of %s or %s", terraformer.StateKindConfigMap, terraformer.StateKindSecret)
+	}
+	if o.stateChunkSize < 0 || o.stateChunkSize > terraformer.MaxStateChunkSize {
+		return fmt.Errorf("flag --state-chunk-size must be between 0 and %d", terraformer.MaxStateChunkSize)
+	}
+	if o.stateHistoryLimit < 0 {
+		return fmt.Errorf("flag --state-history-limit must not be negative")
+	}
+	if len(o.stateEncryptionKeyID) > 0 && len(o.stateEncryptionKeyDir) == 0 {
+		return fmt.Errorf("flag --state-encryption-key-id requires --state-encryption-key-dir to be set")
+	}
+	if len(o.stateEncryptionKeyDir) > 0 && len(o.stateEncryptionKeyID) == 0 {
+		// otherwise, an encrypted state would silently be stored unencrypted again
+		return fmt.Errorf("flag --state-encryption-key-dir requires --state-encryption-key-id to be set")
+	}
+	if o.leaseDuration != 0 && o.leaseDuration < time.Second {
+		return fmt.Errorf("flag --lease-duration must be either 0 or at least 1s")
+	}
+	if o.leaseWaitTimeout < 0 {
+		return fmt.Errorf("flag --lease-wait-timeout must not be negative")
+	}
+	if policy := terraformer.LeasePolicy(o.leasePolicy); len(policy) > 0 && policy != terraformer.LeasePolicySteal && policy != terraformer.LeasePolicyWait {
+		return fmt.Errorf("flag --lease-policy must be one of %s or %s", terraformer.LeasePolicySteal, terraformer.LeasePolicyWait)
+	}
+	if o.stateUpdateDebounce < 0 {
+		return fmt.Errorf("flag --state-update-debounce must not be negative")
+	}
+	if o.stateUpdateMaxDelay < 0 {
+		return fmt.Errorf("flag --state-update-max-delay must not be negative")
+	}
+	if o.stateUpdateMaxDelay > 0 && o.stateUpdateMaxDelay < o.stateUpdateDebounce {
+		return fmt.Errorf("flag --state-update-max-delay must not be lower than --state-update-debounce")
+	}
+	if o.stateFilePollInterval != 0 && o.stateFilePollInterval < 100*time.Millisecond {
+		return fmt.Errorf("flag --state-file-poll-interval must be either 0 or at least 100ms")
+	}
+	if (o.command == terraformer.StateRm || o.command == terraformer.StateMv) && len(o.stateOperations) == 0 && len(o.stateOperationsConfigMapName) == 0 {
+		return fmt.Errorf("either flag --%s or --operations-configmap-name must be set", stateOperationFlag(o.command))
+	}
+	if o.parallelism < 0 {
+		return fmt.Errorf("flag --parallelism must not be negative")
+	}
+	if o.lockTimeout < 0 {
+		return fmt.Errorf("flag --lock-timeout must not be negative")
+	}
+
+	if _, err := o.parseVariablesSources(); err != nil {
+		return err
+	}
+	if _, err := parseProviderMigrations(o.providerMigrations); err != nil {
+		return err
+	}
+
+	extraArgs, err := parseExtraArgs(o.extraArgs)
+	if err != nil {
+		return err
+	}
+	for command, args := range extraArgs {
+		for _, arg := range args {
+			if arg == "-lock=false" && o.lockTimeout > 0 {
+				return fmt.Errorf("flag --lock-timeout can't be combined with extra argument %s for %s", arg, command)
+			}
+			if strings.HasPrefix(arg, "-lockfile=") && o.strictProviderLock {
+				return fmt.Errorf("flag --strict-provider-lock can't be combined with extra argument %s for %s", arg, command)
+			}
+		}
+	}
 
 	return nil
 }
 
+// parseVariablesSources parses the variables sources given in form `kind/name` followed by the Secrets, whose keys
+// are passed as environment variables.
+func (o *Options) parseVariablesSources() ([]terraformer.VariablesSource, error) {
+	var sources []terraformer.VariablesSource
+	for _, value := range o.variablesSources {
+		kind, name, ok := strings.Cut(value, "/")
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("flag --variables-source must be of the form kind/name: %q", value)
+		}
+		if kind := terraformer.VariablesSourceKind(kind); kind != terraformer.VariablesSourceSecret && kind != terraformer.VariablesSourceConfigMap {
+			return nil, fmt.Errorf("flag --variables-source must be of kind %s or %s: %q", terraformer.VariablesSourceSecret, terraformer.VariablesSourceConfigMap, value)
+		}
+		sources = append(sources, terraformer.VariablesSource{Kind: terraformer.VariablesSourceKind(kind), Name: name})
+	}
+	for _, name := range o.variablesEnvSecretNames {
+		if len(name) == 0 {
+			return nil, fmt.Errorf("flag --variables-env-secret-name must not be empty")
+		}
+		sources = append(sources, terraformer.VariablesSource{Kind: terraformer.VariablesSourceSecret, Name: name, Env: true})
+	}
+	return sources, nil
+}
+
+// parseExtraArgs parses the given `command=arg` pairs into the extra arguments per command.
+func parseExtraArgs(pairs []string) (map[terraformer.Command][]string, error) {
+	var extraArgs map[terraformer.Command][]string
+	for _, pair := range pairs {
+		command, arg, ok := strings.Cut(pair, "=")
+		if !ok || len(arg) == 0 {
+			return nil, fmt.Errorf("flag --extra-arg must be of the form command=arg: %q", pair)
+		}
+		if err := terraformer.ValidateExtraArg(terraformer.Command(command), arg); err != nil {
+			return nil, fmt.Errorf("flag --extra-arg is invalid: %w", err)
+		}
+
+		if extraArgs == nil {
+			extraArgs = map[terraformer.Command][]string{}
+		}
+		extraArgs[terraformer.Command(command)] = append(extraArgs[terraformer.Command(command)], arg)
+	}
+	return extraArgs, nil
+}
+
+// parseProviderMigrations parses the given `from=to` pairs of provider sources into the provider migrations.
+func parseProviderMigrations(pairs []string) (map[string]string, error) {
+	var migrations map[string]string
+	for _, pair := range pairs {
+		from, to, err := terraformer.ParseProviderMigration(pair)
+		if err != nil {
+			return nil, fmt.Errorf("flag --provider-migration is invalid: %w", err)
+		}
+
+		if migrations == nil {
+			migrations = map[string]string{}
+		}
+		migrations[from] = to
+	}
+	return migrations, nil
+}
+
 // AddFlags adds command line flags to a pflag.FlagSet
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.kubeconfig, clientcmd.RecommendedConfigPathFlag, "", "Path to a kubeconfig. If unset, the KUBECONFIG env var or in-cluster config will be used")
 	fs.StringVarP(&o.namespace, "namespace", "n", "", "Namespace to store the configuration resources in. If unset, the NAMESPACE env var or the in-cluster config will be used")
-	fs.StringVar(&o.configurationConfigMapName, "configuration-configmap-name", "", "Name of the ConfigMap that holds the main.tf and variables.tf files")
-	fs.StringVar(&o.stateConfigMapName, "state-configmap-name", "", "Name of the ConfigMap that the terraform.tfstate file should be stored in")
+	fs.StringVar(&o.configurationConfigMapName, "configuration-configmap-name", "", "Name of the ConfigMap that holds the main.tf and variables.tf files and further terraform config files, '"+terraformer.ConfigPathSeparator+"' in keys separates directories")
+	fs.StringArrayVar(&o.extraConfigurationConfigMapNames, "extra-configuration-configmap-name", nil, "Name of an additional ConfigMap that holds terraform config files (e.g. shared modules), can be repeated")
+	fs.StringVar(&o.stateConfigMapName, "state-configmap-name", "", "Name of the ConfigMap (or Secret, see --state-kind) that the terraform.tfstate file should be stored in")
+	fs.StringVar(&o.stateKind, "state-kind", string(terraformer.StateKindConfigMap), "Kind of the object that the terraform.tfstate file should be stored in, either ConfigMap or Secret")
 	fs.StringVar(&o.variablesSecretName, "variables-secret-name", "", "Name of the Secret that holds the terraform.tfvars file")
+	fs.StringArrayVar(&o.variablesSources, "variables-source", nil, "Additional source of variables in form kind/name (kind is Secret or ConfigMap), whose .tfvars and .tfvars.json keys are passed as var files after terraform.tfvars, can be repeated, later sources take precedence")
+	fs.StringArrayVar(&o.variablesEnvSecretNames, "variables-env-secret-name", nil, "Name of a Secret whose keys are passed as TF_VAR_<key> environment variables, which take precedence below all var files, can be repeated")
+	fs.StringVar(&o.planSecretName, "plan-secret-name", "", "Name of the Secret that the plan file created by terraformer plan should be stored in, if given terraformer apply applies the stored plan")
+	fs.StringVar(&o.outputsConfigMapName, "outputs-configmap-name", "", "Name of the ConfigMap that non-sensitive terraform outputs should be exported to after a successful apply or refresh")
+	fs.StringVar(&o.outputsSecretName, "outputs-secret-name", "", "Name of the Secret that sensitive terraform outputs should be exported to after a successful apply or refresh")
+	fs.StringVar(&o.driftReportConfigMapName, "drift-report-configmap-name", "", "Name of the ConfigMap that the drift report created by terraformer drift should be stored in")
+	fs.StringVar(&o.importConfigMapName, "import-configmap-name", "", "Name of the ConfigMap that holds the list of resources to import by terraformer import")
+	fs.StringVar(&o.importConfigMapKey, "import-configmap-key", terraformer.DefaultImportConfigMapKey, "Key of the import list in the import ConfigMap, which holds one address=id pair per line")
+	fs.StringArrayVar(&o.targets, "target", nil, "Address of a resource that terraformer apply and destroy should be limited to, can be repeated")
+	fs.StringArrayVar(&o.replace, "replace", nil, "Address of a resource that terraformer apply should force to be replaced, can be repeated")
+	fs.StringVar(&o.targetConfigMapName, "target-configmap-name", "", "Name of a ConfigMap that holds additional target and replace addresses in its "+terraformer.TargetConfigMapTargetsKey+" and "+terraformer.TargetConfigMapReplaceKey+" keys, one per line")
+	fs.IntVar(&o.parallelism, "parallelism", terraformer.DefaultParallelism, "Number of concurrent operations of terraform plan, apply and destroy")
+	fs.DurationVar(&o.lockTimeout, "lock-timeout", 0, "Duration terraform retries acquiring the state lock, if 0 terraform fails immediately if the state is locked")
+	fs.BoolVar(&o.skipRefresh, "skip-refresh", false, "Skip refreshing the state before planning (terraform -refresh=false), which speeds up runs for large states but might miss changes made outside of terraform")
+	fs.StringArrayVar(&o.extraArgs, "extra-arg", nil, "Additional argument for a terraform command in form command=arg (e.g. apply=-compact-warnings), can be repeated, only a limited set of arguments is allowed")
+	fs.StringVar(&o.providerLockConfigMapName, "provider-lock-configmap-name", "", "Name of the ConfigMap that the provider dependency lock file (.terraform.lock.hcl) should be stored in after terraform init and restored from before terraform init")
+	fs.BoolVar(&o.strictProviderLock, "strict-provider-lock", false, "Fail terraform init if the providers don't match the recorded versions and hashes instead of updating the lock file, which has to be stored in --provider-lock-configmap-name or contained in the config")
+	fs.StringArrayVar(&o.providerMigrations, "provider-migration", nil, "Migration of a provider source in the state in form from=to (e.g. registry.terraform.io/-/aws=hashicorp/aws), in addition to the built-in migrations of legacy providers, can be repeated")
+	fs.StringVar(&o.providerMigrationConfigMapName, "provider-migration-configmap-name", "", "Name of a ConfigMap that holds additional provider migrations in its "+terraformer.ProviderMigrationConfigMapKey+" key, one from=to pair per line")
 	fs.StringVar(&o.baseDir, "base-dir", "", "Base directory to be used for all terraform files (defaults to '/')")
+	fs.IntVar(&o.stateChunkSize, "state-chunk-size", terraformer.DefaultStateChunkSize, "Maximum size of the state in bytes that is stored in a single ConfigMap, larger states are split across multiple chunk ConfigMaps")
+	fs.IntVar(&o.stateHistoryLimit, "state-history-limit", 0, "Number of state revisions to keep in separate history objects for rolling back the state, if 0 no state history is kept")
+	fs.BoolVar(&o.compressState, "compress-state", false, "Store the state gzip compressed, states stored without compression can still be read")
+	fs.StringVar(&o.stateEncryptionKeyDir, "state-encryption-key-dir", "", "Directory (e.g. a mounted Secret) holding the keys for encrypting and decrypting the state, the file names are used as key IDs")
+	fs.StringVar(&o.stateEncryptionKeyID, "state-encryption-key-id", "", "ID of the key used for encrypting the state, if unset the state is stored unencrypted")
+	fs.DurationVar(&o.leaseDuration, "lease-duration", 0, "Duration of the Lease named after the state ConfigMap, that terraformer holds while running to prevent concurrent runs, if 0 no Lease is acquired")
+	fs.DurationVar(&o.leaseWaitTimeout, "lease-wait-timeout", 5*time.Minute, "Maximum duration to wait for a Lease held by another terraformer to be released")
+	fs.StringVar(&o.leasePolicy, "lease-policy", string(terraformer.LeasePolicySteal), "How to handle stale Leases of crashed terraformers, either Steal (take them over) or Wait (wait for them to be released)")
+	fs.DurationVar(&o.stateUpdateDebounce, "state-update-debounce", 0, "Duration the state file has to be unchanged before the state ConfigMap is updated, so that bursts of state file writes are collapsed into a single update, if 0 every write triggers an update")
+	fs.DurationVar(&o.stateUpdateMaxDelay, "state-update-max-delay", time.Minute, "Maximum duration the update of the state ConfigMap is delayed by --state-update-debounce, if 0 the update is delayed until the state file doesn't change anymore")
+	fs.DurationVar(&o.stateFilePollInterval, "state-file-poll-interval", 0, "Interval for polling the state file for changes of its modification time and size, e.g. on filesystems without inotify support, if 0 the state directory is watched with inotify")
+	fs.BoolVar(&o.httpBackend, "http-backend", false, "Serve terraform's http backend on the loopback interface, which reads and writes the state ConfigMap directly, instead of watching a local state file")
+	fs.BoolVar(&o.forceStateUpdate, "force-state-update", false, "Overwrite the stored state even if the state has a different lineage or a lower serial, use with care as this might discard a newer state")
+}
+
+// AddStateOperationsFlags adds the command line flags of the given state command (terraformer.StateRm or
+// terraformer.StateMv) to a pflag.FlagSet
+func (o *Options) AddStateOperationsFlags(fs *pflag.FlagSet, command terraformer.Command) {
+	usage := "Address of a resource to remove from the state, can be given multiple times"
+	if command == terraformer.StateMv {
+		usage = "Pair of resource addresses in form source=destination to move in the state, can be given multiple times"
+	}
+
+	fs.StringArrayVar(&o.stateOperations, stateOperationFlag(command), nil, usage)
+	fs.StringVar(&o.stateOperationsConfigMapName, "operations-configmap-name", "", "Name of a ConfigMap that holds additional operations, one per line")
+	fs.StringVar(&o.stateOperationsConfigMapKey, "operations-configmap-key", terraformer.DefaultStateOperationsConfigMapKey, "Key of the operations in the operations ConfigMap")
+}
+
+// stateOperationFlag returns the name of the flag, that holds the operations of the given state command.
+func stateOperationFlag(command terraformer.Command) string {
+	if command == terraformer.StateMv {
+		return "move"
+	}
+	return "address"
 }
 
 // Completed returns the completed terraformer.Config
@@ -5,14 +5,19 @@
 package cmd
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/gardener/gardener/pkg/utils/test"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gstruct"
 	"github.com/onsi/gomega/types"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
 )
 
 var _ = Describe("Options", func() {
@@ -69,6 +74,93 @@ var _ = Describe("Options", func() {
 				Expect(completed.StateConfigMapName).To(Equal(stateConfigMapName))
 				Expect(completed.VariablesSecretName).To(Equal(variablesSecretName))
 			})
+			It("should use the given plan secret name", func() {
+				opts.planSecretName = "tf-plan"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.PlanSecretName).To(Equal("tf-plan"))
+			})
+			It("should use the given outputs object names", func() {
+				opts.outputsConfigMapName = "tf-outputs"
+				opts.outputsSecretName = "tf-sensitive-outputs"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.OutputsConfigMapName).To(Equal("tf-outputs"))
+				Expect(completed.OutputsSecretName).To(Equal("tf-sensitive-outputs"))
+			})
+			It("should use the given drift report configmap name", func() {
+				opts.driftReportConfigMapName = "tf-drift"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.DriftReportConfigMapName).To(Equal("tf-drift"))
+			})
+			It("should use the given import configmap name and key", func() {
+				opts.importConfigMapName = "tf-imports"
+				opts.importConfigMapKey = "resources"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.ImportConfigMapName).To(Equal("tf-imports"))
+				Expect(completed.ImportConfigMapKey).To(Equal("resources"))
+			})
+			It("should use the given state operations", func() {
+				opts.stateOperations = []string{"aws_vpc.main=module.network.aws_vpc.main"}
+				opts.stateOperationsConfigMapName = "tf-state-operations"
+				opts.stateOperationsConfigMapKey = "moves"
+				Expect(opts.CompleteForCommand(terraformer.StateMv)).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.StateOperations).To(Equal([]string{"aws_vpc.main=module.network.aws_vpc.main"}))
+				Expect(completed.StateOperationsConfigMapName).To(Equal("tf-state-operations"))
+				Expect(completed.StateOperationsConfigMapKey).To(Equal("moves"))
+			})
+			It("should fail if neither --address nor --operations-configmap-name is set for state rm", func() {
+				Expect(opts.CompleteForCommand(terraformer.StateRm)).To(MatchError("either flag --address or --operations-configmap-name must be set"))
+			})
+			It("should fail if neither --move nor --operations-configmap-name is set for state mv", func() {
+				Expect(opts.CompleteForCommand(terraformer.StateMv)).To(MatchError("either flag --move or --operations-configmap-name must be set"))
+			})
+			It("should use the given variables sources", func() {
+				opts.variablesSources = []string{"ConfigMap/tf-tuning", "Secret/tf-credentials"}
+				opts.variablesEnvSecretNames = []string{"tf-env"}
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.VariablesSources).To(Equal([]terraformer.VariablesSource{
+					{Kind: terraformer.VariablesSourceConfigMap, Name: "tf-tuning"},
+					{Kind: terraformer.VariablesSourceSecret, Name: "tf-credentials"},
+					{Kind: terraformer.VariablesSourceSecret, Name: "tf-env", Env: true},
+				}))
+			})
+			It("should fail if --variables-source is malformed", func() {
+				opts.variablesSources = []string{"tf-tuning"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("must be of the form kind/name")))
+			})
+			It("should fail if --variables-source has an unsupported kind", func() {
+				opts.variablesSources = []string{"Deployment/tf-tuning"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("variables-source must be of kind")))
+			})
+			It("should use the given extra configuration configmap names", func() {
+				opts.extraConfigurationConfigMapNames = []string{"tf-modules", "tf-templates"}
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.ExtraConfigurationConfigMapNames).To(Equal([]string{"tf-modules", "tf-templates"}))
+			})
+			It("should use the given targets", func() {
+				opts.targets = []string{"aws_vpc.main", "aws_subnet.a"}
+				opts.replace = []string{"aws_instance.broken"}
+				opts.targetConfigMapName = "tf-targets"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.Targets).To(ConsistOf("aws_vpc.main", "aws_subnet.a"))
+				Expect(completed.Replace).To(ConsistOf("aws_instance.broken"))
+				Expect(completed.TargetConfigMapName).To(Equal("tf-targets"))
+			})
 			It("should use empty base dir if omitted", func() {
 				opts.baseDir = ""
 				Expect(opts.Complete()).To(Succeed())
@@ -84,6 +176,212 @@ var _ = Describe("Options", func() {
 				completed := opts.Completed()
 				Expect(completed.BaseDir).To(Equal(baseDir))
 			})
+			It("should use the given state chunk size", func() {
+				opts.stateChunkSize = 512
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.StateChunkSize).To(Equal(512))
+			})
+			It("should fail if --state-chunk-size is negative", func() {
+				opts.stateChunkSize = -1
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-chunk-size")))
+			})
+			It("should fail if --state-chunk-size exceeds the object size limit", func() {
+				opts.stateChunkSize = 2 * 1024 * 1024
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-chunk-size")))
+			})
+			It("should accept --state-chunk-size at the object size limit", func() {
+				opts.stateChunkSize = terraformer.MaxStateChunkSize
+				Expect(opts.Complete()).To(Succeed())
+
+				By("rejecting chunks, which leave no room for the key")
+				opts.stateChunkSize = 1024 * 1024
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-chunk-size")))
+			})
+			It("should fail if --state-history-limit is negative", func() {
+				opts.stateHistoryLimit = -1
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-history-limit")))
+			})
+			It("should use the given state kind", func() {
+				opts.stateKind = "Secret"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.StateKind).To(Equal(terraformer.StateKindSecret))
+			})
+			It("should fail if --state-kind is invalid", func() {
+				opts.stateKind = "Deployment"
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-kind")))
+			})
+			It("should load the state encryption keys", func() {
+				keyDir, err := ioutil.TempDir("", "tf-keys-*")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.RemoveAll(keyDir)
+				Expect(ioutil.WriteFile(filepath.Join(keyDir, "key-1"), bytes.Repeat([]byte{1}, 32), 0600)).To(Succeed())
+
+				opts.stateEncryptionKeyDir = keyDir
+				opts.stateEncryptionKeyID = "key-1"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.StateEncryptionKeys.ActiveKeyID).To(Equal("key-1"))
+				Expect(completed.StateEncryptionKeys.Keys).To(HaveKey("key-1"))
+			})
+			It("should fail if --state-encryption-key-id is set without --state-encryption-key-dir", func() {
+				opts.stateEncryptionKeyID = "key-1"
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-encryption-key-dir")))
+			})
+			It("should fail if --state-encryption-key-dir is set without --state-encryption-key-id", func() {
+				opts.stateEncryptionKeyDir = "/tmp/keys"
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-encryption-key-id")))
+			})
+			It("should use the given lease options", func() {
+				opts.leaseDuration = 15 * time.Second
+				opts.leaseWaitTimeout = time.Minute
+				opts.leasePolicy = "Wait"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.LeaseDuration).To(Equal(15 * time.Second))
+				Expect(completed.LeaseWaitTimeout).To(Equal(time.Minute))
+				Expect(completed.LeasePolicy).To(Equal(terraformer.LeasePolicyWait))
+			})
+			It("should fail if --lease-duration is too short", func() {
+				opts.leaseDuration = time.Millisecond
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("lease-duration")))
+			})
+			It("should fail if --lease-policy is invalid", func() {
+				opts.leasePolicy = "Ignore"
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("lease-policy")))
+			})
+			It("should use the given state update delays", func() {
+				opts.stateUpdateDebounce = 5 * time.Second
+				opts.stateUpdateMaxDelay = time.Minute
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.StateUpdateDebounce).To(Equal(5 * time.Second))
+				Expect(completed.StateUpdateMaxDelay).To(Equal(time.Minute))
+			})
+			It("should fail if --state-update-debounce is negative", func() {
+				opts.stateUpdateDebounce = -time.Second
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-update-debounce")))
+			})
+			It("should fail if --state-update-max-delay is lower than --state-update-debounce", func() {
+				opts.stateUpdateDebounce = time.Minute
+				opts.stateUpdateMaxDelay = time.Second
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-update-max-delay")))
+			})
+			It("should use the given state file poll interval", func() {
+				opts.stateFilePollInterval = time.Second
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.StateFilePollInterval).To(Equal(time.Second))
+			})
+			It("should fail if --state-file-poll-interval is too short", func() {
+				opts.stateFilePollInterval = time.Millisecond
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("state-file-poll-interval")))
+			})
+			It("should use the given http backend option", func() {
+				opts.httpBackend = true
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.HTTPBackend).To(BeTrue())
+			})
+			It("should use the given force state update option", func() {
+				opts.forceStateUpdate = true
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.ForceStateUpdate).To(BeTrue())
+			})
+			It("should use the given terraform run options", func() {
+				opts.parallelism = 20
+				opts.lockTimeout = time.Minute
+				opts.skipRefresh = true
+				opts.extraArgs = []string{"init=-upgrade", "apply=-compact-warnings", "destroy=-compact-warnings"}
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.Parallelism).To(Equal(20))
+				Expect(completed.LockTimeout).To(Equal(time.Minute))
+				Expect(completed.SkipRefresh).To(BeTrue())
+				Expect(completed.ExtraArgs).To(Equal(map[terraformer.Command][]string{
+					terraformer.Init:    {"-upgrade"},
+					terraformer.Apply:   {"-compact-warnings"},
+					terraformer.Destroy: {"-compact-warnings"},
+				}))
+			})
+			It("should fail if --parallelism is negative", func() {
+				opts.parallelism = -1
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("parallelism")))
+			})
+			It("should fail if --lock-timeout is negative", func() {
+				opts.lockTimeout = -time.Second
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("lock-timeout")))
+			})
+			It("should fail if --extra-arg is malformed", func() {
+				opts.extraArgs = []string{"-compact-warnings"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("must be of the form command=arg")))
+			})
+			It("should fail if --extra-arg is not allowed", func() {
+				opts.extraArgs = []string{"apply=-state=/tmp/other.tfstate"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring(`argument "-state=/tmp/other.tfstate" is not allowed`)))
+			})
+			It("should fail if --extra-arg is given for an unsupported command", func() {
+				opts.extraArgs = []string{"validate=-json"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("not supported")))
+			})
+			It("should fail if --lock-timeout is combined with disabled locking", func() {
+				opts.lockTimeout = time.Minute
+				opts.extraArgs = []string{"destroy=-lock=false"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("lock-timeout")))
+			})
+			It("should fail if --extra-arg sets variables", func() {
+				opts.extraArgs = []string{"apply=-var=password=secret"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring(`argument "-var=password=secret" is not allowed`)))
+			})
+			It("should fail if --extra-arg sets a plugin dir", func() {
+				opts.extraArgs = []string{"init=-plugin-dir=/tmp/plugins"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring(`argument "-plugin-dir=/tmp/plugins" is not allowed`)))
+			})
+			It("should use the given provider lock options", func() {
+				opts.providerLockConfigMapName = "tf-provider-lock"
+				opts.strictProviderLock = true
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.ProviderLockConfigMapName).To(Equal("tf-provider-lock"))
+				Expect(completed.StrictProviderLock).To(BeTrue())
+			})
+			It("should fail if --strict-provider-lock is combined with -lockfile", func() {
+				opts.strictProviderLock = true
+				opts.extraArgs = []string{"init=-lockfile=readonly"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("strict-provider-lock")))
+			})
+			It("should use the given provider migrations", func() {
+				opts.providerMigrations = []string{"registry.terraform.io/-/aws=hashicorp/aws", "example/foo=registry.example.com/example/foo"}
+				opts.providerMigrationConfigMapName = "tf-provider-migrations"
+				Expect(opts.Complete()).To(Succeed())
+
+				completed := opts.Completed()
+				Expect(completed.ProviderMigrations).To(Equal(map[string]string{
+					"registry.terraform.io/-/aws":       "registry.terraform.io/hashicorp/aws",
+					"registry.terraform.io/example/foo": "registry.example.com/example/foo",
+				}))
+				Expect(completed.ProviderMigrationConfigMapName).To(Equal("tf-provider-migrations"))
+			})
+			It("should fail if --provider-migration is malformed", func() {
+				opts.providerMigrations = []string{"hashicorp/aws"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("must be of the form from=to")))
+			})
+			It("should fail if --provider-migration contains an invalid source", func() {
+				opts.providerMigrations = []string{"aws=hashicorp/aws"}
+				Expect(opts.Complete()).To(MatchError(ContainSubstring("[hostname/]namespace/type")))
+			})
 			It("should fail if --configuration-configmap-name is unset", func() {
 				opts.configurationConfigMapName = ""
 				Expect(opts.Complete()).To(MatchError(ContainSubstring("configuration-configmap-name")))
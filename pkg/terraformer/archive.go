@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ConfigArchiveKey is the key of a gzip compressed tar archive in configuration ConfigMaps, which is unpacked into
+	// the config dir. It allows shipping whole module trees in a single key of the ConfigMap's binaryData.
+	ConfigArchiveKey = "config.tar.gz"
+
+	// maxConfigArchiveEntries is the maximum number of entries (files and directories) in a config archive.
+	maxConfigArchiveEntries = 1000
+	// maxConfigArchiveSize is the maximum total size of the entries of a config archive.
+	maxConfigArchiveSize = 64 * 1024 * 1024
+	// maxConfigArchiveStreamSize is the maximum size of the decompressed archive, i.e. the entries plus the overhead of
+	// their headers (including long names), which limits decompressing the archive independent of the entries.
+	maxConfigArchiveStreamSize = maxConfigArchiveSize + maxConfigArchiveEntries*8*1024
+)
+
+// unpackConfigArchive unpacks the given gzip compressed tar archive into the config dir. Only regular files and
+// directories are allowed, the archive is rejected if it contains symlinks or other file types, paths outside of the
+// config dir, or exceeds the limits for the number of entries and their total size. addFile is called with the path of
+// every file relative to the config dir before it is written.
+func (t *Terraformer) unpackConfigArchive(reader io.Reader, addFile func(file string) error) error {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer gzipReader.Close()
+
+	var (
+		limitedReader = &io.LimitedReader{R: gzipReader, N: maxConfigArchiveStreamSize}
+		tarReader     = tar.NewReader(limitedReader)
+		entries       int
+		size          int64
+	)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return checkArchiveLimit(limitedReader, fmt.Errorf("failed to read archive: %w", err))
+		}
+
+		// all entries count toward the limits, regardless of their type
+		if entries++; entries > maxConfigArchiveEntries {
+			return fmt.Errorf("archive contains more than %d entries", maxConfigArchiveEntries)
+		}
+		if size += header.Size; size > maxConfigArchiveSize {
+			return fmt.Errorf("entries of archive exceed %d bytes", maxConfigArchiveSize)
+		}
+
+		if header.Typeflag == tar.TypeDir && filepath.Clean(header.Name) == "." {
+			continue
+		}
+		file, err := checkConfigFile(filepath.FromSlash(header.Name))
+		if err != nil {
+			return err
+		}
+		filePath := filepath.Join(t.paths.ConfigDir, file)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(filePath, 0750); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			return fmt.Errorf("%q is not a regular file or directory", header.Name)
+		}
+
+		if err := addFile(file); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+			return err
+		}
+		if err := writeFile(filePath, tarReader); err != nil {
+			return checkArchiveLimit(limitedReader, err)
+		}
+	}
+}
+
+// checkArchiveLimit returns an error stating that the decompressed archive exceeds its limit, if the given error was
+// caused by the given reader truncating the archive. Otherwise, the given error is returned.
+func checkArchiveLimit(limitedReader *io.LimitedReader, err error) error {
+	if limitedReader.N <= 0 && errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("decompressed archive exceeds %d bytes", maxConfigArchiveStreamSize)
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultParallelism is the default number of concurrent operations of terraform plan, apply and destroy.
+const DefaultParallelism = 4
+
+// AllowedExtraArgs contains the arguments per command, that can be passed to terraform in addition to the arguments
+// managed by terraformer. Arguments are matched by their name, i.e. the part before `=`. All other arguments are
+// rejected, as they would interfere with how terraformer runs terraform (e.g. `-state` or `-chdir`, or `-input`, as
+// terraform runs without stdin), would bypass the provider lock file (`-plugin-dir`) or would put variables (e.g.
+// credentials) on the command line, which is logged (`-var`, use variables sources instead).
+var AllowedExtraArgs = map[Command][]string{
+	Init:    {"-upgrade", "-get", "-lockfile"},
+	Plan:    {"-lock", "-compact-warnings"},
+	Apply:   {"-lock", "-compact-warnings"},
+	Destroy: {"-lock", "-compact-warnings"},
+}
+
+// ValidateExtraArg checks whether the given argument is allowed as extra argument for the given command.
+func ValidateExtraArg(command Command, arg string) error {
+	allowed, ok := AllowedExtraArgs[command]
+	if !ok {
+		return fmt.Errorf("extra arguments are not supported for terraform command %q", command)
+	}
+
+	name, _, _ := strings.Cut(arg, "=")
+	if !slices.Contains(allowed, name) {
+		return fmt.Errorf("argument %q is not allowed for terraform command %q, allowed arguments are %s", arg, command, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// runArgs returns the configured arguments for tuning how terraform runs the given command, followed by the extra
+// arguments for the command.
+func (t *Terraformer) runArgs(command Command) []string {
+	var args []string
+
+	switch command {
+	case Plan, Apply, Destroy:
+		parallelism := t.config.Parallelism
+		if parallelism == 0 {
+			parallelism = DefaultParallelism
+		}
+		args = append(args, "-parallelism="+strconv.Itoa(parallelism))
+	}
+
+	switch command {
+	case Plan, Apply, Destroy, Import, StateRm, StateMv:
+		if t.config.LockTimeout > 0 {
+			args = append(args, "-lock-timeout="+t.config.LockTimeout.String())
+		}
+	}
+
+	// refresh-only runs can't skip the refresh, and stored plans were already refreshed when they were created
+	if (command == Plan || command == Apply || command == Destroy) && t.config.SkipRefresh &&
+		t.command != Refresh && t.command != Drift && !t.applyingPlan() {
+		args = append(args, "-refresh=false")
+	}
+
+	return append(args, t.config.ExtraArgs[command]...)
+}
@@ -0,0 +1,247 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// httpBackendOverrideFile is the name of the override file in the config dir, that configures terraform to use the
+	// http backend served by terraformer.
+	httpBackendOverrideFile = "terraformer_backend_override.tf"
+	// httpBackendStatePath is the URL path, under which the state is served.
+	httpBackendStatePath = "/state"
+	// httpBackendShutdownTimeout is the timeout for gracefully shutting down the http backend.
+	httpBackendShutdownTimeout = 30 * time.Second
+
+	// methodLock and methodUnlock are the methods used by terraform's http backend for locking and unlocking the state.
+	methodLock   = "LOCK"
+	methodUnlock = "UNLOCK"
+)
+
+const httpBackendOverrideTemplate = `# generated by terraformer, do not edit
+terraform {
+  backend "http" {
+    address        = %[1]q
+    lock_address   = %[1]q
+    unlock_address = %[1]q
+    lock_method    = %[2]q
+    unlock_method  = %[3]q
+  }
+}
+`
+
+// lockInfo is the lock information sent by terraform when locking and unlocking the state. Only the ID is relevant
+// for terraformer, all other fields are kept as they are to return them to terraform on lock conflicts.
+type lockInfo struct {
+	ID string `json:"ID"`
+}
+
+// httpBackend implements terraform's http backend protocol on top of the state object.
+type httpBackend struct {
+	t   *Terraformer
+	log logr.Logger
+
+	// mutex serializes all requests, terraform doesn't send concurrent requests anyway
+	mutex sync.Mutex
+	// lock holds the raw lock info of the current lock, nil if the state is not locked
+	lock []byte
+	// lockID is the ID of the current lock
+	lockID string
+}
+
+// StartHTTPBackend starts a loopback http server implementing terraform's http backend protocol, which reads and
+// writes the state object directly. It generates a backend override file in the config dir, that configures terraform
+// to use the server as its backend, so that every state write of terraform directly goes to the API server and
+// terraform's state locking is enforced. Stored states are also written to the state file, so that the final state
+// update keeps working as usual.
+// It returns a func that should be executed as part of the shutdown procedure, which stops the server.
+func (t *Terraformer) StartHTTPBackend() (func(), error) {
+	log := t.log.WithName("http-backend")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for http backend: %w", err)
+	}
+
+	address := fmt.Sprintf("http://%s%s", listener.Addr().String(), httpBackendStatePath)
+	overrideFile := filepath.Join(t.paths.ConfigDir, httpBackendOverrideFile)
+	log.V(1).Info("writing backend override file", "file", overrideFile)
+	if err := os.WriteFile(overrideFile, []byte(fmt.Sprintf(httpBackendOverrideTemplate, address, methodLock, methodUnlock)), 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(httpBackendStatePath, &httpBackend{t: t, log: log})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err, "http backend failed")
+		}
+	}()
+
+	log.Info("started http backend", "address", address)
+	return func() {
+		log.V(1).Info("stopping http backend")
+		ctx, cancel := context.WithTimeout(context.Background(), httpBackendShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			log.Error(err, "failed to stop http backend")
+		}
+		<-done
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (b *httpBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	log := b.log.WithValues("method", r.Method)
+	log.V(1).Info("handling request")
+
+	switch r.Method {
+	case http.MethodGet:
+		b.getState(w, r, log)
+	case http.MethodPost:
+		b.storeState(w, r, log)
+	case methodLock:
+		b.lockState(w, r, log)
+	case methodUnlock:
+		b.unlockState(w, r, log)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+func (b *httpBackend) getState(w http.ResponseWriter, r *http.Request, log logr.Logger) {
+	state, err := b.t.readState(r.Context(), b.t.config.StateConfigMapName)
+	if err != nil {
+		log.Error(err, "failed to read state")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// terraform treats no content as an empty state
+	if len(state) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(state)
+}
+
+func (b *httpBackend) storeState(w http.ResponseWriter, r *http.Request, log logr.Logger) {
+	// terraform passes the ID of its lock when storing the state, reject writes of anyone not holding the lock
+	if b.lock != nil && r.URL.Query().Get("ID") != b.lockID {
+		log.Info("rejecting state update of client not holding the lock", "lockID", b.lockID)
+		b.writeLock(w, http.StatusConflict)
+		return
+	}
+
+	state, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := b.t.storeState(r.Context(), b.t.newStateObject(b.t.config.StateConfigMapName), bytes.NewReader(state)); err != nil {
+		log.Error(err, "failed to store state")
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, errStaleState) || errors.Is(err, errLeaseLost) {
+			statusCode = http.StatusConflict
+		}
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+
+	// keep the state file in sync, it is used for the final state update and as a last resort if the API server
+	// becomes unavailable
+	if err := os.WriteFile(b.t.paths.StatePath, state, 0600); err != nil {
+		log.Error(err, "failed to write state file")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("successfully stored state")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *httpBackend) lockState(w http.ResponseWriter, r *http.Request, log logr.Logger) {
+	info, lock, err := readLockInfo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if b.lock != nil && b.lockID != info.ID {
+		log.Info("state is already locked", "lockID", b.lockID)
+		b.writeLock(w, http.StatusLocked)
+		return
+	}
+
+	b.lock, b.lockID = lock, info.ID
+	log.Info("locked state", "lockID", info.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *httpBackend) unlockState(w http.ResponseWriter, r *http.Request, log logr.Logger) {
+	info, _, err := readLockInfo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if b.lock != nil && b.lockID != info.ID {
+		log.Info("rejecting unlock of client not holding the lock", "lockID", b.lockID)
+		b.writeLock(w, http.StatusConflict)
+		return
+	}
+
+	b.lock, b.lockID = nil, ""
+	log.Info("unlocked state", "lockID", info.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeLock responds with the given status code and the current lock info, which terraform shows to the user.
+func (b *httpBackend) writeLock(w http.ResponseWriter, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(b.lock)
+}
+
+// readLockInfo reads the lock info from the request body and returns it together with the raw lock info.
+func readLockInfo(r *http.Request) (*lockInfo, []byte, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := &lockInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode lock info: %w", err)
+	}
+	return info, data, nil
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("Terraformer HTTP Backend", func() {
+	var (
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+
+		shutdown func()
+		address  string
+
+		request func(method, url, body string) (int, string)
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		tf, err := terraformer.NewTerraformer(
+			&terraformer.Config{
+				Namespace:                  testObjs.Namespace,
+				ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+				StateConfigMapName:         testObjs.StateConfigMap.Name,
+				VariablesSecretName:        testObjs.VariablesSecret.Name,
+				RESTConfig:                 restConfig,
+				HTTPBackend:                true,
+			},
+			runtimelog.Log,
+			paths,
+			clock.RealClock{},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tf.EnsureTFDirs()).To(Succeed())
+
+		shutdown, err = tf.StartHTTPBackend()
+		Expect(err).NotTo(HaveOccurred())
+
+		overrideFile, err := os.ReadFile(filepath.Join(paths.ConfigDir, "terraformer_backend_override.tf"))
+		Expect(err).NotTo(HaveOccurred())
+		match := regexp.MustCompile(`address\s+=\s+"([^"]+)"`).FindStringSubmatch(string(overrideFile))
+		Expect(match).To(HaveLen(2))
+		address = match[1]
+
+		request = func(method, url, body string) (int, string) {
+			req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			respBody, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return resp.StatusCode, string(respBody)
+		}
+	})
+
+	AfterEach(func() {
+		shutdown()
+		testutils.RunCleanupActions()
+	})
+
+	It("should generate a backend override file", func() {
+		Expect(filepath.Join(paths.ConfigDir, "terraformer_backend_override.tf")).To(testutils.BeFileWithContents(And(
+			ContainSubstring(`backend "http"`),
+			ContainSubstring(`lock_method    = "LOCK"`),
+			ContainSubstring(`unlock_method  = "UNLOCK"`),
+		)))
+		Expect(address).To(HavePrefix("http://127.0.0.1:"))
+	})
+
+	It("should serve the stored state", func() {
+		code, body := request(http.MethodGet, address, "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(testObjs.StateConfigMap.Data[testutils.StateKey]))
+	})
+
+	It("should serve no content if the state is empty", func() {
+		Expect(testClient.Delete(ctx, testObjs.StateConfigMap)).To(Succeed())
+
+		code, _ := request(http.MethodGet, address, "")
+		Expect(code).To(Equal(http.StatusNoContent))
+	})
+
+	It("should store the state in the state ConfigMap and the state file", func() {
+		code, _ := request(http.MethodPost, address, `{"serial":1}`)
+		Expect(code).To(Equal(http.StatusOK))
+
+		configMap := &corev1.ConfigMap{}
+		Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKeyWithValue(testutils.StateKey, `{"serial":1}`))
+		Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(`{"serial":1}`)))
+	})
+
+	It("should enforce terraform's lock", func() {
+		code, _ := request("LOCK", address, `{"ID":"lock-1"}`)
+		Expect(code).To(Equal(http.StatusOK))
+
+		By("rejecting other locks")
+		code, body := request("LOCK", address, `{"ID":"lock-2"}`)
+		Expect(code).To(Equal(http.StatusLocked))
+		Expect(body).To(Equal(`{"ID":"lock-1"}`))
+
+		By("rejecting state updates without the lock")
+		code, _ = request(http.MethodPost, address+"?ID=lock-2", `{"serial":1}`)
+		Expect(code).To(Equal(http.StatusConflict))
+		code, _ = request(http.MethodPost, address+"?ID=lock-1", `{"serial":1}`)
+		Expect(code).To(Equal(http.StatusOK))
+
+		By("rejecting unlocks without the lock")
+		code, _ = request("UNLOCK", address, `{"ID":"lock-2"}`)
+		Expect(code).To(Equal(http.StatusConflict))
+		code, _ = request("UNLOCK", address, `{"ID":"lock-1"}`)
+		Expect(code).To(Equal(http.StatusOK))
+
+		code, _ = request("LOCK", address, `{"ID":"lock-2"}`)
+		Expect(code).To(Equal(http.StatusOK))
+	})
+})
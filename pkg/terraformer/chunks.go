@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// DefaultStateChunkSize is the default maximum size of a state that is stored in a single object.
+	// Larger states are split across multiple chunk objects to stay below the object size limit of the API server.
+	DefaultStateChunkSize = 768 * 1024
+	// MaxStateChunkSize is the maximum size of a state chunk. The API server rejects ConfigMaps and Secrets, whose data
+	// including the key names exceeds 1MiB, so some headroom is left for the key.
+	MaxStateChunkSize = 1024*1024 - 16*1024
+
+	// LabelStateChunkOf is the label on chunk objects holding the name of the state object they belong to.
+	LabelStateChunkOf = "terraformer.gardener.cloud/state-chunk-of"
+
+	// tfStateManifestKey is the key under which the manifest of a chunked state is stored in the state object.
+	tfStateManifestKey = "terraform.tfstate.manifest"
+)
+
+// stateManifest describes a state that is split across multiple chunk objects. If the state object contains a
+// manifest, the state key itself is left empty.
+type stateManifest struct {
+	// Chunks is the ordered list of names of the chunk objects.
+	Chunks []string `json:"chunks"`
+	// Size is the total size of the state in bytes.
+	Size int `json:"size"`
+	// SHA256 is the hex-encoded checksum of the complete state.
+	SHA256 string `json:"sha256"`
+}
+
+func (t *Terraformer) stateChunkSize() int {
+	if t.config.StateChunkSize > 0 {
+		return min(t.config.StateChunkSize, MaxStateChunkSize)
+	}
+	return DefaultStateChunkSize
+}
+
+// storeChunkedState splits the given state into chunk objects and stores a manifest referencing them in the state
+// object. Chunks are written before the state object, so that the manifest never references missing chunks. The chunk
+// names contain the state's checksum, i.e. the chunks of the previous state stay intact until they are cleaned up
+// after the manifest has been updated.
+func (t *Terraformer) storeChunkedState(ctx context.Context, log logr.Logger, obj Store, state []byte) error {
+	owner, err := t.ensureStateObject(ctx, obj.Object().GetName())
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(state)
+	manifest := stateManifest{
+		Size:   len(state),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	chunkSize := t.stateChunkSize()
+	for start := 0; start < len(state); {
+		end := min(start+chunkSize, len(state))
+		// don't split multi-byte characters, ConfigMap data has to be valid UTF-8
+		for end < len(state) && end > start+1 && !utf8.RuneStart(state[end]) {
+			end--
+		}
+
+		chunk := t.newStateObject(fmt.Sprintf("%s-chunk-%s-%d", owner.GetName(), manifest.SHA256[:10], len(manifest.Chunks)))
+		chunk.Object().SetLabels(map[string]string{LabelStateChunkOf: owner.GetName()})
+		if err := controllerutil.SetOwnerReference(owner, chunk.Object(), t.client.Scheme()); err != nil {
+			return err
+		}
+		if err := chunk.Store(tfStateKey, bytes.NewReader(state[start:end])); err != nil {
+			return err
+		}
+		start = end
+
+		log.V(1).Info("creating state chunk", "chunk", chunk.Object().GetName())
+		// chunks are immutable, an existing chunk with the same name already holds the same contents
+		if err := t.client.Create(ctx, chunk.Object()); client.IgnoreAlreadyExists(err) != nil {
+			return fmt.Errorf("failed to create state chunk %q: %w", chunk.Object().GetName(), err)
+		}
+		manifest.Chunks = append(manifest.Chunks, chunk.Object().GetName())
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := obj.Store(tfStateKey, &bytes.Buffer{}); err != nil {
+		return err
+	}
+	if err := obj.Store(tfStateManifestKey, bytes.NewReader(manifestData)); err != nil {
+		return err
+	}
+
+	log.V(1).Info("storing state manifest", "chunks", len(manifest.Chunks), "size", manifest.Size)
+	if err := storeObject(ctx, log, t.client, obj); err != nil {
+		return err
+	}
+
+	return t.cleanupStateChunks(ctx, log, owner.GetName(), manifest.Chunks...)
+}
+
+// ensureStateObject returns the state object with the given name and creates an empty one if it doesn't exist yet.
+// It is needed as the owner of the state chunks, so that they get garbage collected together with the state object.
+func (t *Terraformer) ensureStateObject(ctx context.Context, name string) (client.Object, error) {
+	obj := t.newStateObject(name).Object()
+	if err := t.client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err := t.client.Create(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// cleanupStateChunks deletes all chunk objects belonging to the given state object except for the given ones.
+func (t *Terraformer) cleanupStateChunks(ctx context.Context, log logr.Logger, name string, keep ...string) error {
+	list := t.newStateObjectList()
+	if err := t.client.List(ctx, list, client.InNamespace(t.config.Namespace), client.MatchingLabels{LabelStateChunkOf: name}); err != nil {
+		return fmt.Errorf("failed to list state chunks: %w", err)
+	}
+
+	keepChunks := make(map[string]struct{}, len(keep))
+	for _, chunk := range keep {
+		keepChunks[chunk] = struct{}{}
+	}
+
+	chunks, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range chunks {
+		chunk, ok := obj.(client.Object)
+		if !ok {
+			continue
+		}
+		if _, ok := keepChunks[chunk.GetName()]; ok {
+			continue
+		}
+		log.V(1).Info("deleting leftover state chunk", "chunk", chunk.GetName())
+		if err := t.client.Delete(ctx, chunk); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete leftover state chunk %q: %w", chunk.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// joinStateChunks checks if the given state object contains a manifest instead of the state itself. If so, it fetches
+// all chunks, verifies the checksum of the joined state and stores it under the state key of the given object.
+// A non-empty state key always takes precedence over a manifest. It returns whether the state was chunked.
+func (t *Terraformer) joinStateChunks(ctx context.Context, obj Store) (bool, error) {
+	if state, err := readValue(obj, tfStateKey); ignoreKeyNotFound(err) != nil || len(state) > 0 {
+		return false, ignoreKeyNotFound(err)
+	}
+
+	manifestData, err := readValue(obj, tfStateManifestKey)
+	if err != nil || len(manifestData) == 0 {
+		return false, ignoreKeyNotFound(err)
+	}
+
+	manifest := &stateManifest{}
+	if err := json.Unmarshal(manifestData, manifest); err != nil {
+		return true, fmt.Errorf("could not unmarshal state manifest from JSON: %w", err)
+	}
+
+	state := make([]byte, 0, manifest.Size)
+	for _, name := range manifest.Chunks {
+		chunk := t.newStateObject(name)
+		if err := t.client.Get(ctx, client.ObjectKeyFromObject(chunk.Object()), chunk.Object()); err != nil {
+			return true, fmt.Errorf("failed to fetch state chunk %q: %w", name, err)
+		}
+		data, err := readValue(chunk, tfStateKey)
+		if err != nil {
+			return true, fmt.Errorf("failed reading state chunk %q: %w", name, err)
+		}
+		state = append(state, data...)
+	}
+
+	if sum := sha256.Sum256(state); hex.EncodeToString(sum[:]) != manifest.SHA256 || len(state) != manifest.Size {
+		return true, fmt.Errorf("checksum of joined state chunks does not match the state manifest")
+	}
+
+	return true, obj.Store(tfStateKey, bytes.NewReader(state))
+}
+
+func readValue(obj Store, key string) ([]byte, error) {
+	reader, err := obj.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func ignoreKeyNotFound(err error) error {
+	var notFoundErr KeyNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil
+	}
+	return err
+}
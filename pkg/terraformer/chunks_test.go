@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("Terraformer State Chunks", func() {
+	const chunkSize = 16
+
+	var (
+		tf       *terraformer.Terraformer
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+
+		listChunks func() []corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		tf, err = terraformer.NewTerraformer(
+			&terraformer.Config{
+				Namespace:                  testObjs.Namespace,
+				ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+				StateConfigMapName:         testObjs.StateConfigMap.Name,
+				VariablesSecretName:        testObjs.VariablesSecret.Name,
+				RESTConfig:                 restConfig,
+				StateChunkSize:             chunkSize,
+			},
+			runtimelog.Log,
+			paths,
+			clock.RealClock{},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tf.EnsureTFDirs()).To(Succeed())
+
+		listChunks = func() []corev1.ConfigMap {
+			list := &corev1.ConfigMapList{}
+			Expect(testClient.List(ctx, list, client.InNamespace(testObjs.Namespace), client.MatchingLabels{
+				terraformer.LabelStateChunkOf: testObjs.StateConfigMap.Name,
+			})).To(Succeed())
+			return list.Items
+		}
+	})
+
+	AfterEach(func() {
+		testutils.RunCleanupActions()
+	})
+
+	It("should store small states in a single ConfigMap", func() {
+		stateContents := "small state"
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		testObjs.Refresh()
+		Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateContents))
+		Expect(listChunks()).To(BeEmpty())
+	})
+
+	It("should split large states into chunks and join them again", func() {
+		stateContents := strings.Repeat("large state ", 5)
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		testObjs.Refresh()
+		Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, ""))
+		Expect(testObjs.StateConfigMap.Data).To(HaveKey(testutils.StateManifestKey))
+
+		chunks := listChunks()
+		Expect(chunks).To(HaveLen(4))
+		for _, chunk := range chunks {
+			Expect(len(chunk.Data[testutils.StateKey])).To(BeNumerically("<=", chunkSize))
+			Expect(chunk.OwnerReferences).To(ConsistOf(HaveField("UID", testObjs.StateConfigMap.UID)))
+		}
+
+		Expect(os.WriteFile(paths.StatePath, nil, 0644)).To(Succeed())
+		Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+		Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(stateContents)))
+	})
+
+	It("should store chunks of the maximum chunk size", func() {
+		var err error
+		tf, err = terraformer.NewTerraformer(
+			&terraformer.Config{
+				Namespace:                  testObjs.Namespace,
+				ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+				StateConfigMapName:         testObjs.StateConfigMap.Name,
+				VariablesSecretName:        testObjs.VariablesSecret.Name,
+				RESTConfig:                 restConfig,
+				StateChunkSize:             terraformer.MaxStateChunkSize,
+			},
+			runtimelog.Log,
+			paths,
+			clock.RealClock{},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		stateContents := strings.Repeat("x", terraformer.MaxStateChunkSize+1)
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		chunks := listChunks()
+		Expect(chunks).To(HaveLen(2))
+		Expect(chunks).To(ContainElement(HaveField("Data", HaveKeyWithValue(testutils.StateKey, HaveLen(terraformer.MaxStateChunkSize)))))
+	})
+
+	It("should cleanup leftover chunks of a larger state", func() {
+		Expect(os.WriteFile(paths.StatePath, []byte(strings.Repeat("large state ", 5)), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+		Expect(listChunks()).To(HaveLen(4))
+
+		By("storing a smaller, but still chunked state")
+		stateContents := strings.Repeat("large state ", 2)
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+		Expect(listChunks()).To(HaveLen(2))
+
+		By("storing a state fitting into a single ConfigMap")
+		stateContents = "small state"
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+		Expect(listChunks()).To(BeEmpty())
+
+		testObjs.Refresh()
+		Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateContents))
+		Expect(testObjs.StateConfigMap.Data).NotTo(HaveKey(testutils.StateManifestKey))
+	})
+
+	It("should fail fetching the state if a chunk was modified", func() {
+		Expect(os.WriteFile(paths.StatePath, []byte(strings.Repeat("large state ", 5)), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		chunk := listChunks()[0]
+		chunk.Data[testutils.StateKey] = "corrupted"
+		Expect(testClient.Update(ctx, &chunk)).To(Succeed())
+
+		Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("checksum")))
+	})
+
+	It("should fail fetching the state if a chunk is missing", func() {
+		Expect(os.WriteFile(paths.StatePath, []byte(strings.Repeat("large state ", 5)), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		chunk := listChunks()[0]
+		Expect(testClient.Delete(ctx, &chunk)).To(Succeed())
+
+		Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("not found")))
+	})
+})
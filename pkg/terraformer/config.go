@@ -9,8 +9,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-multierror"
@@ -27,6 +30,10 @@ const (
 	tfConfigVarsKey = "variables.tf"
 	tfVarsKey       = "terraform.tfvars"
 	tfStateKey      = "terraform.tfstate"
+
+	// ConfigPathSeparator separates the directories in the keys of configuration ConfigMaps, as keys can't contain `/`.
+	// E.g., the key `modules__network__main.tf` is written to `modules/network/main.tf` in the config dir.
+	ConfigPathSeparator = "__"
 )
 
 // EnsureTFDirs ensures that the needed directories for the terraform files are present.
@@ -48,20 +55,19 @@ func (t *Terraformer) FetchConfigAndState(ctx context.Context) error {
 	)
 
 	wg.Start(func() {
-		errCh <- fetchConfigMap(ctx, log, t.client, t.config.Namespace, t.config.ConfigurationConfigMapName, false,
-			t.paths.ConfigDir, tfConfigMainKey, tfConfigVarsKey,
-		)
+		errCh <- t.fetchConfig(ctx, log)
 	})
 	wg.Start(func() {
-		errCh <- fetchConfigMap(ctx, log, t.client, t.config.Namespace, t.config.StateConfigMapName, true,
-			t.paths.StateDir, tfStateKey,
-		)
+		errCh <- t.fetchState(ctx, log)
 	})
 	wg.Start(func() {
 		errCh <- fetchSecret(ctx, log, t.client, t.config.Namespace, t.config.VariablesSecretName, false,
 			t.paths.VarsDir, tfVarsKey,
 		)
 	})
+	wg.Start(func() {
+		errCh <- t.fetchVariablesSources(ctx, log)
+	})
 
 	go func() {
 		defer close(errCh)
@@ -71,16 +77,184 @@ func (t *Terraformer) FetchConfigAndState(ctx context.Context) error {
 	for err := range errCh {
 		allErrs = multierror.Append(allErrs, err)
 	}
+	if err := allErrs.ErrorOrNil(); err != nil {
+		return err
+	}
 
-	return allErrs.ErrorOrNil()
+	// the lock file is restored after the config, as it is written to the config dir as well
+	if err := t.restoreLockFile(ctx, log); err != nil {
+		return fmt.Errorf("failed to restore provider lock file: %w", err)
+	}
+
+	configSHA256, err := t.configChecksum()
+	if err != nil {
+		return err
+	}
+	t.configSHA256 = configSHA256
+	log.V(1).Info("fetched terraform config", "files", len(t.configFiles), "sha256", configSHA256)
+	return nil
 }
 
-func fetchConfigMap(ctx context.Context, log logr.Logger, c client.Client, ns, name string, optional bool, dir string, dataKeys ...string) error {
-	return fetchObject(ctx, log, c, "ConfigMap", ns, name, &ConfigMapStore{&corev1.ConfigMap{}}, optional, dir, dataKeys...)
+// fetchConfig writes every key of the configuration ConfigMap and the extra configuration ConfigMaps to the config dir,
+// keys containing ConfigPathSeparator are written to the respective subdirectories and ConfigArchiveKey is unpacked into
+// the config dir. The configuration ConfigMap has to contain the `main.tf` and `variables.tf` files.
+func (t *Terraformer) fetchConfig(ctx context.Context, log logr.Logger) error {
+	// fileSources records the ConfigMap, that each config file was written from, to detect conflicting keys
+	fileSources := make(map[string]string)
+
+	for i, name := range append([]string{t.config.ConfigurationConfigMapName}, t.config.ExtraConfigurationConfigMapNames...) {
+		key := client.ObjectKey{Namespace: t.config.Namespace, Name: name}
+		log := log.WithValues("kind", "ConfigMap", "object", key, "dir", t.paths.ConfigDir)
+		log.V(1).Info("fetching object")
+
+		configMap := &corev1.ConfigMap{}
+		if err := t.client.Get(ctx, key, configMap); err != nil {
+			return err
+		}
+		obj := &EncodingStore{Underlying: &ConfigMapStore{configMap}}
+
+		dataKeys := make([]string, 0, len(configMap.Data)+len(configMap.BinaryData))
+		for dataKey := range configMap.Data {
+			dataKeys = append(dataKeys, dataKey)
+		}
+		for dataKey := range configMap.BinaryData {
+			dataKeys = append(dataKeys, dataKey)
+		}
+		slices.Sort(dataKeys)
+
+		addFile := func(file string) error {
+			if source, ok := fileSources[file]; ok {
+				return fmt.Errorf("config file %q is contained in both ConfigMap %q and %q", file, source, name)
+			}
+			fileSources[file] = name
+			return nil
+		}
+
+		for _, dataKey := range dataKeys {
+			reader, err := obj.Read(dataKey)
+			if err != nil {
+				return fmt.Errorf("failed reading from ConfigMap %q: %w", key, err)
+			}
+
+			if dataKey == ConfigArchiveKey {
+				log.V(1).Info("unpacking config archive", "dataKey", dataKey)
+				if err := t.unpackConfigArchive(reader, addFile); err != nil {
+					return fmt.Errorf("failed to unpack %s of ConfigMap %q: %w", dataKey, key, err)
+				}
+				continue
+			}
+
+			file, err := configFilePath(dataKey)
+			if err != nil {
+				return fmt.Errorf("invalid key in ConfigMap %q: %w", key, err)
+			}
+			if err := addFile(file); err != nil {
+				return err
+			}
+
+			filePath := filepath.Join(t.paths.ConfigDir, file)
+			log.V(1).Info("copying contents to file", "dataKey", dataKey, "file", filePath)
+			if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+				return err
+			}
+			if err := writeFile(filePath, reader); err != nil {
+				return err
+			}
+		}
+
+		if i == 0 {
+			for _, requiredFile := range []string{tfConfigMainKey, tfConfigVarsKey} {
+				if _, ok := fileSources[requiredFile]; !ok {
+					return fmt.Errorf("failed reading from ConfigMap %q: %w", key, KeyNotFoundError(requiredFile))
+				}
+			}
+		}
+	}
+
+	t.configFiles = slices.Sorted(maps.Keys(fileSources))
+	return nil
+}
+
+// configFilePath returns the path relative to the config dir, that the given key of a configuration ConfigMap is
+// written to.
+func configFilePath(key string) (string, error) {
+	parts := strings.Split(key, ConfigPathSeparator)
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("key %q doesn't denote a path inside the config dir", key)
+		}
+	}
+	return checkConfigFile(filepath.Join(parts...))
+}
+
+// checkConfigFile cleans the given path of a config file relative to the config dir. It rejects paths outside of the
+// config dir and paths of files managed by terraform or terraformer.
+func checkConfigFile(file string) (string, error) {
+	cleaned := filepath.Clean(file)
+	if !filepath.IsLocal(cleaned) {
+		return "", fmt.Errorf("%q doesn't denote a path inside the config dir", file)
+	}
+	if strings.Split(cleaned, string(filepath.Separator))[0] == ".terraform" || cleaned == tfPlanKey || cleaned == httpBackendOverrideFile {
+		return "", fmt.Errorf("%q denotes a file managed by terraformer", file)
+	}
+	return cleaned, nil
+}
+
+// writeFile truncates the given file and copies the contents of the given reader to it.
+func writeFile(filePath string, reader io.Reader) error {
+	file, err := os.OpenFile(filepath.Clean(filePath), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, reader)
+	return err
+}
+
+// fetchState fetches the state object and writes the (joined) state to the state file. If the state object doesn't
+// exist, the state file is truncated, as the state object is the single source of truth.
+func (t *Terraformer) fetchState(ctx context.Context, log logr.Logger) error {
+	state, obj, chunked, err := t.readStateObject(ctx, t.config.StateConfigMapName)
+	if err != nil {
+		return err
+	}
+	// remember the fetched state, so that it isn't overwritten by older states
+	t.stateMutex.Lock()
+	t.storedState = newStoredState(state, obj)
+	t.stateChunked = chunked
+	t.stateMutex.Unlock()
+
+	log.V(1).Info("copying state to file", "file", t.paths.StatePath)
+	return os.WriteFile(t.paths.StatePath, state, 0600)
+}
+
+// readState returns the state stored in the state object with the given name and joins the state chunks if necessary.
+// It returns an empty state if the object or the state key doesn't exist.
+func (t *Terraformer) readState(ctx context.Context, name string) ([]byte, error) {
+	state, _, _, err := t.readStateObject(ctx, name)
+	return state, err
+}
+
+// readStateObject is like readState but additionally returns the state object, which is nil if it doesn't exist, and
+// whether the state is split into chunks.
+func (t *Terraformer) readStateObject(ctx context.Context, name string) ([]byte, client.Object, bool, error) {
+	obj := t.newStateObject(name)
+	if err := t.client.Get(ctx, client.ObjectKeyFromObject(obj.Object()), obj.Object()); err != nil {
+		return nil, nil, false, client.IgnoreNotFound(err)
+	}
+
+	chunked, err := t.joinStateChunks(ctx, obj)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	state, err := readValue(t.stateStore(obj), tfStateKey)
+	return state, obj.Object(), chunked, ignoreKeyNotFound(err)
 }
 
 func fetchSecret(ctx context.Context, log logr.Logger, c client.Client, ns, name string, optional bool, dir string, dataKeys ...string) error {
-	return fetchObject(ctx, log, c, "Secret", ns, name, &SecretStore{&corev1.Secret{}}, optional, dir, dataKeys...)
+	return fetchObject(ctx, log, c, "Secret", ns, name, &EncodingStore{Underlying: &SecretStore{&corev1.Secret{}}}, optional, dir, dataKeys...)
 }
 
 func fetchObject(ctx context.Context, log logr.Logger, c client.Client, kind, ns, name string, obj Store, optional bool, dir string, dataKeys ...string) error {
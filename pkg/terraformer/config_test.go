@@ -5,12 +5,18 @@
 package terraformer_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -19,6 +25,51 @@ import (
 	testutils "github.com/gardener/terraformer/test/utils"
 )
 
+type archiveEntry struct {
+	name, contents, linkname string
+	typeflag                 byte
+}
+
+// configArchive returns a gzip compressed tar archive with the given entries, which are regular files by default.
+func configArchive(entries ...archiveEntry) []byte {
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Linkname: entry.linkname, Mode: 0600}
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.contents))
+		}
+		Expect(tarWriter.WriteHeader(header)).To(Succeed())
+		_, err := tarWriter.Write([]byte(entry.contents))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tarWriter.Close()).To(Succeed())
+	Expect(gzipWriter.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+// newConfigTestTerraformer returns a Terraformer for the given test objects, that fetches the given extra
+// configuration ConfigMaps.
+func newConfigTestTerraformer(testObjs *testutils.TestObjects, paths *terraformer.PathSet, extraConfigMapNames ...string) *terraformer.Terraformer {
+	tf, err := terraformer.NewTerraformer(
+		&terraformer.Config{
+			Namespace:                        testObjs.Namespace,
+			ConfigurationConfigMapName:       testObjs.ConfigurationConfigMap.Name,
+			ExtraConfigurationConfigMapNames: extraConfigMapNames,
+			StateConfigMapName:               testObjs.StateConfigMap.Name,
+			VariablesSecretName:              testObjs.VariablesSecret.Name,
+			RESTConfig:                       restConfig,
+		},
+		runtimelog.Log,
+		paths,
+		clock.RealClock{},
+	)
+	Expect(err).NotTo(HaveOccurred())
+	return tf
+}
+
 var _ = Describe("Terraformer Config", func() {
 	var (
 		tf       *terraformer.Terraformer
@@ -109,6 +160,115 @@ var _ = Describe("Terraformer Config", func() {
 
 				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("not found")))
 			})
+			It("should write all keys and nested module directories to the config dir", func() {
+				testObjs.ConfigurationConfigMap.Data["outputs.tf"] = `output "foo" {}`
+				testObjs.ConfigurationConfigMap.Data["modules"+terraformer.ConfigPathSeparator+"network"+terraformer.ConfigPathSeparator+"main.tf"] = `resource "null_resource" "bar" {}`
+				testObjs.ConfigurationConfigMap.BinaryData = map[string][]byte{"user-data.tftpl": []byte("#!/bin/bash")}
+				Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+
+				Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+
+				Expect(filepath.Join(paths.ConfigDir, "outputs.tf")).To(testutils.BeFileWithContents(Equal(`output "foo" {}`)))
+				Expect(filepath.Join(paths.ConfigDir, "modules", "network", "main.tf")).To(testutils.BeFileWithContents(Equal(`resource "null_resource" "bar" {}`)))
+				Expect(filepath.Join(paths.ConfigDir, "user-data.tftpl")).To(testutils.BeFileWithContents(Equal("#!/bin/bash")))
+			})
+			It("should write the keys of the extra configuration ConfigMaps to the config dir", func() {
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-modules"},
+					Data:       map[string]string{"modules" + terraformer.ConfigPathSeparator + "dns.tf": `resource "null_resource" "dns" {}`},
+				})).To(Succeed())
+				tf = newConfigTestTerraformer(testObjs, paths, "tf-modules")
+
+				Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+
+				Expect(filepath.Join(paths.ConfigDir, "modules", "dns.tf")).To(testutils.BeFileWithContents(Equal(`resource "null_resource" "dns" {}`)))
+			})
+			It("should fail if an extra configuration ConfigMap is not present", func() {
+				tf = newConfigTestTerraformer(testObjs, paths, "tf-modules")
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("not found")))
+			})
+			It("should fail if a config file is contained in multiple ConfigMaps", func() {
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-modules"},
+					Data:       map[string]string{testutils.ConfigMainKey: ""},
+				})).To(Succeed())
+				tf = newConfigTestTerraformer(testObjs, paths, "tf-modules")
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring(`config file "main.tf" is contained in both ConfigMap`)))
+			})
+			It("should reject keys outside of the config dir", func() {
+				testObjs.ConfigurationConfigMap.Data[".."+terraformer.ConfigPathSeparator+"tfvars"+terraformer.ConfigPathSeparator+"terraform.tfvars"] = "SOME_VAR = \"evil\""
+				Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("doesn't denote a path inside the config dir")))
+				Expect(paths.VarsPath).NotTo(testutils.BeFileWithContents(ContainSubstring("evil")))
+			})
+			It("should reject keys of files managed by terraformer", func() {
+				testObjs.ConfigurationConfigMap.Data[".terraform"+terraformer.ConfigPathSeparator+"terraform.tfstate"] = "{}"
+				Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("managed by terraformer")))
+			})
+		})
+
+		Context("config archive", func() {
+			storeArchive := func(entries ...archiveEntry) {
+				testObjs.ConfigurationConfigMap.Data = nil
+				testObjs.ConfigurationConfigMap.BinaryData = map[string][]byte{terraformer.ConfigArchiveKey: configArchive(entries...)}
+				Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+			}
+
+			It("should unpack the config archive into the config dir", func() {
+				storeArchive(
+					archiveEntry{name: testutils.ConfigMainKey, contents: "main"},
+					archiveEntry{name: testutils.ConfigVarsKey, contents: "vars"},
+					archiveEntry{name: "modules/", typeflag: tar.TypeDir},
+					archiveEntry{name: "modules/network/main.tf", contents: "network"},
+				)
+
+				Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+
+				Expect(filepath.Join(paths.ConfigDir, testutils.ConfigMainKey)).To(testutils.BeFileWithContents(Equal("main")))
+				Expect(filepath.Join(paths.ConfigDir, testutils.ConfigVarsKey)).To(testutils.BeFileWithContents(Equal("vars")))
+				Expect(filepath.Join(paths.ConfigDir, "modules", "network", "main.tf")).To(testutils.BeFileWithContents(Equal("network")))
+			})
+			It("should fail if the config archive doesn't contain the main config", func() {
+				storeArchive(archiveEntry{name: testutils.ConfigVarsKey, contents: "vars"})
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("not found")))
+			})
+			It("should reject symlinks", func() {
+				storeArchive(
+					archiveEntry{name: testutils.ConfigMainKey, contents: "main"},
+					archiveEntry{name: testutils.ConfigVarsKey, typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+				)
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("is not a regular file or directory")))
+			})
+			It("should reject paths outside of the config dir", func() {
+				storeArchive(archiveEntry{name: "../tfvars/terraform.tfvars", contents: "evil"})
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("doesn't denote a path inside the config dir")))
+			})
+			It("should reject archives with too many files", func() {
+				entries := []archiveEntry{{name: testutils.ConfigMainKey}, {name: testutils.ConfigVarsKey}}
+				for i := 0; i < 1000; i++ {
+					entries = append(entries, archiveEntry{name: fmt.Sprintf("file-%d.tf", i)})
+				}
+				storeArchive(entries...)
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("more than 1000 entries")))
+			})
+			It("should count directories toward the entry limit", func() {
+				entries := []archiveEntry{{name: testutils.ConfigMainKey}, {name: testutils.ConfigVarsKey}}
+				for i := 0; i < 1000; i++ {
+					entries = append(entries, archiveEntry{name: fmt.Sprintf("dir-%d/", i), typeflag: tar.TypeDir})
+				}
+				storeArchive(entries...)
+
+				Expect(tf.FetchConfigAndState(ctx)).To(MatchError(ContainSubstring("more than 1000 entries")))
+			})
 		})
 
 		Context("variables fetching", func() {
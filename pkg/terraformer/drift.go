@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/gardener/terraformer/pkg/utils"
+)
+
+const (
+	// DriftReportKey is the key of the drift report in the drift report ConfigMap.
+	DriftReportKey = "drift-report.json"
+
+	// ExitCodeDrift is the exit code of terraformer if the drift command detected resources, that were changed outside
+	// of terraform.
+	ExitCodeDrift = 12
+)
+
+// errDrift is returned by the drift command if drift was detected.
+var errDrift = errors.New("detected drift of resources")
+
+// DriftReport is the report of the drift command, which is stored in the drift report ConfigMap.
+type DriftReport struct {
+	// Time is the time the drift was checked at.
+	Time metav1.Time `json:"time"`
+	// DriftDetected is true if any resource was changed outside of terraform.
+	DriftDetected bool `json:"driftDetected"`
+	// Resources lists the resources, that were changed outside of terraform.
+	Resources []DriftedResource `json:"resources"`
+}
+
+// DriftedResource is a resource, that was changed outside of terraform.
+type DriftedResource struct {
+	// Address is the absolute address of the resource, e.g. `module.foo.aws_vpc.main`.
+	Address string `json:"address"`
+	// Type is the resource type, e.g. `aws_vpc`.
+	Type string `json:"type"`
+	// Actions are the actions terraform reports for the change, e.g. `update` or `delete` if the resource is gone.
+	Actions []string `json:"actions"`
+	// Attributes are the names of the top-level attributes, that were changed. Values are not reported, as they might
+	// be sensitive.
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// jsonPlan is the part of the JSON plan representation (`terraform show -json`), that is relevant for drift detection.
+type jsonPlan struct {
+	ResourceDrift []jsonResourceChange `json:"resource_drift"`
+}
+
+// jsonResourceChange is a single resource change of the JSON plan representation.
+type jsonResourceChange struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Change  struct {
+		Actions []string                   `json:"actions"`
+		Before  map[string]json.RawMessage `json:"before"`
+		After   map[string]json.RawMessage `json:"after"`
+	} `json:"change"`
+}
+
+// detectDrift creates a refresh-only plan, which doesn't modify the state or any resources, and stores a report of
+// the resources, that were changed outside of terraform, in the drift report ConfigMap. If any drift was detected, an
+// error with ExitCodeDrift is returned.
+func (t *Terraformer) detectDrift(ctx context.Context) error {
+	log := t.stepLogger("detectDrift")
+
+	if err := t.executeTerraform(ctx, Plan, "-refresh-only", "-out="+t.paths.PlanPath); err != nil {
+		return fmt.Errorf("error executing terraform %s: %w", Plan, err)
+	}
+
+	planJSON, err := t.showPlan(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing terraform %s: %w", Show, err)
+	}
+
+	report, err := newDriftReport(planJSON, t.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	obj := &ConfigMapStore{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace: t.config.Namespace,
+		Name:      t.config.DriftReportConfigMapName,
+	}}}
+	if err := obj.Store(DriftReportKey, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	reportLog := log.WithValues("configMap", client.ObjectKeyFromObject(obj.Object()), "driftedResources", len(report.Resources))
+	if err := storeObject(ctx, reportLog, t.client, obj); err != nil {
+		return fmt.Errorf("failed to store drift report: %w", err)
+	}
+
+	if report.DriftDetected {
+		reportLog.Info("detected drift of resources")
+		return utils.WithTerraformerExitCode{Code: ExitCodeDrift, Underlying: errDrift}
+	}
+
+	reportLog.Info("no drift detected")
+	return nil
+}
+
+// newDriftReport returns the drift report for the given JSON plan representation.
+func newDriftReport(planJSON []byte, now time.Time) (*DriftReport, error) {
+	plan := &jsonPlan{}
+	if err := json.Unmarshal(planJSON, plan); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+
+	report := &DriftReport{Time: metav1.NewTime(now), Resources: []DriftedResource{}}
+	for _, change := range plan.ResourceDrift {
+		report.Resources = append(report.Resources, DriftedResource{
+			Address:    change.Address,
+			Type:       change.Type,
+			Actions:    change.Change.Actions,
+			Attributes: changedAttributes(change.Change.Before, change.Change.After),
+		})
+	}
+	report.DriftDetected = len(report.Resources) > 0
+
+	return report, nil
+}
+
+// changedAttributes returns the sorted names of the attributes, that differ between before and after.
+func changedAttributes(before, after map[string]json.RawMessage) []string {
+	var attributes []string
+	for name, value := range before {
+		if afterValue, ok := after[name]; !ok || !jsonEqual(value, afterValue) {
+			attributes = append(attributes, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			attributes = append(attributes, name)
+		}
+	}
+
+	sort.Strings(attributes)
+	return attributes
+}
+
+// jsonEqual returns whether the given JSON values are equal, ignoring insignificant whitespace.
+func jsonEqual(a, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
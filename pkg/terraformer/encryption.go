@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationEncryptionKeyID is the annotation recording the ID of the key, that was used for encrypting the data key
+	// of the values stored by an EncryptingStore.
+	AnnotationEncryptionKeyID = "terraformer.gardener.cloud/encryption-key-id"
+	// AnnotationEncryptionDataKey is the annotation holding the encrypted data key of the values stored by an
+	// EncryptingStore.
+	AnnotationEncryptionDataKey = "terraformer.gardener.cloud/encryption-data-key"
+
+	// encryptionKeySize is the size of the keys in bytes, i.e. AES-256 is used.
+	encryptionKeySize = 32
+)
+
+// EncryptionKeys holds the keys for encrypting and decrypting values.
+type EncryptionKeys struct {
+	// ActiveKeyID is the ID of the key used for encrypting values. If empty, values are stored unencrypted.
+	ActiveKeyID string
+	// Keys maps key IDs to AES-256 keys. All keys can be used for decrypting values.
+	Keys map[string][]byte
+}
+
+// LoadEncryptionKeys reads all keys from the given directory, e.g. a mounted Secret. The file names are used as key IDs,
+// the files have to contain 32 byte keys (either raw or base64 encoded).
+func LoadEncryptionKeys(dir, activeKeyID string) (*EncryptionKeys, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption keys: %w", err)
+	}
+
+	keys := &EncryptionKeys{ActiveKeyID: activeKeyID, Keys: make(map[string][]byte, len(entries))}
+	for _, entry := range entries {
+		// skip directories and hidden files, e.g. the `..data` symlink of mounted Secrets
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, entry.Name())); err != nil || info.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Clean(filepath.Join(dir, entry.Name())))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key %q: %w", entry.Name(), err)
+		}
+		if len(data) != encryptionKeySize {
+			if data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err != nil || len(data) != encryptionKeySize {
+				return nil, fmt.Errorf("encryption key %q is not a (base64 encoded) %d byte key", entry.Name(), encryptionKeySize)
+			}
+		}
+		keys.Keys[entry.Name()] = data
+	}
+
+	if _, ok := keys.Keys[activeKeyID]; activeKeyID != "" && !ok {
+		return nil, fmt.Errorf("active encryption key %q not found in %s", activeKeyID, dir)
+	}
+	return keys, nil
+}
+
+var _ Store = &EncryptingStore{}
+
+// EncryptingStore wraps a Store and encrypts all values using envelope encryption: the values are encrypted with a
+// random data key using AES-GCM, and the data key is encrypted with the active key of Keys. The encrypted data key and
+// the ID of the key, that encrypted it, are recorded in annotations on the object. Thereby, keys can be rotated by
+// adding a new active key and keeping the old keys for decryption until all objects have been re-encrypted.
+type EncryptingStore struct {
+	// Underlying is the Store to store the encrypted values in.
+	Underlying Store
+	// Keys holds the keys for encrypting and decrypting values.
+	Keys *EncryptionKeys
+}
+
+// Object returns the object of the underlying Store.
+func (e *EncryptingStore) Object() client.Object {
+	return e.Underlying.Object()
+}
+
+// Read returns a reader for reading the decrypted value of the given key in the underlying Store.
+// Values of objects without encryption annotations are returned as is.
+func (e *EncryptingStore) Read(key string) (io.Reader, error) {
+	reader, err := e.Underlying.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, ok := e.Object().GetAnnotations()[AnnotationEncryptionKeyID]
+	if !ok {
+		return reader, nil
+	}
+
+	dataKey, err := e.dataKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := decrypt(dataKey, data, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value of key %q: %w", key, err)
+	}
+	return bytes.NewReader(plaintext), nil
+}
+
+// Store encrypts the contents of the given reader and stores them under the given key in the underlying Store.
+// If there is no active key, the contents are stored unencrypted.
+func (e *EncryptingStore) Store(key string, data io.Reader) error {
+	annotations := e.Object().GetAnnotations()
+
+	if e.Keys == nil || e.Keys.ActiveKeyID == "" {
+		delete(annotations, AnnotationEncryptionKeyID)
+		delete(annotations, AnnotationEncryptionDataKey)
+		e.Object().SetAnnotations(annotations)
+		return e.Underlying.Store(key, data)
+	}
+
+	// reuse the data key if other values of the object have already been encrypted with it
+	dataKey, err := e.dataKey(annotations[AnnotationEncryptionKeyID])
+	if err != nil || annotations[AnnotationEncryptionKeyID] != e.Keys.ActiveKeyID {
+		if dataKey, err = e.newDataKey(); err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := encrypt(dataKey, plaintext, []byte(key))
+	if err != nil {
+		return err
+	}
+	return e.Underlying.Store(key, bytes.NewReader(ciphertext))
+}
+
+// dataKey decrypts the data key of the object with the key with the given ID.
+func (e *EncryptingStore) dataKey(keyID string) ([]byte, error) {
+	key, ok := e.Keys.lookup(keyID)
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found", keyID)
+	}
+
+	encryptedDataKey, err := base64.StdEncoding.DecodeString(e.Object().GetAnnotations()[AnnotationEncryptionDataKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	dataKey, err := decrypt(key, encryptedDataKey, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with encryption key %q: %w", keyID, err)
+	}
+	return dataKey, nil
+}
+
+// newDataKey generates a new data key and records it encrypted with the active key in the object's annotations.
+func (e *EncryptingStore) newDataKey() ([]byte, error) {
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	encryptedDataKey, err := encrypt(e.Keys.Keys[e.Keys.ActiveKeyID], dataKey, []byte(e.Keys.ActiveKeyID))
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := e.Object().GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 2)
+	}
+	annotations[AnnotationEncryptionKeyID] = e.Keys.ActiveKeyID
+	annotations[AnnotationEncryptionDataKey] = base64.StdEncoding.EncodeToString(encryptedDataKey)
+	e.Object().SetAnnotations(annotations)
+
+	return dataKey, nil
+}
+
+func (k *EncryptionKeys) lookup(keyID string) ([]byte, bool) {
+	if k == nil {
+		return nil, false
+	}
+	key, ok := k.Keys[keyID]
+	return key, ok
+}
+
+// encrypt encrypts the given plaintext with AES-GCM and prepends the random nonce to the returned ciphertext.
+func encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// decrypt decrypts the given ciphertext, which was encrypted by encrypt.
+func decrypt(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,282 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("EncryptingStore", func() {
+	var (
+		s    *terraformer.EncryptingStore
+		cm   *corev1.ConfigMap
+		keys *terraformer.EncryptionKeys
+	)
+
+	BeforeEach(func() {
+		keys = &terraformer.EncryptionKeys{
+			ActiveKeyID: "key-1",
+			Keys: map[string][]byte{
+				"key-1": bytes.Repeat([]byte{1}, 32),
+				"key-2": bytes.Repeat([]byte{2}, 32),
+			},
+		}
+		cm = &corev1.ConfigMap{Data: map[string]string{}}
+		s = &terraformer.EncryptingStore{Underlying: &terraformer.ConfigMapStore{cm}, Keys: keys}
+	})
+
+	It("should return the underlying ConfigMap", func() {
+		Expect(s.Object()).To(BeIdenticalTo(cm))
+	})
+
+	It("should encrypt and decrypt values", func() {
+		Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+		Expect(s.Store("baz", bytes.NewBufferString("qux"))).To(Succeed())
+		Expect(cm.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-1"))
+		Expect(cm.Annotations).To(HaveKey(terraformer.AnnotationEncryptionDataKey))
+		Expect(cm.BinaryData).To(HaveKey("foo"))
+		Expect(cm.BinaryData["foo"]).NotTo(ContainSubstring("bar"))
+
+		reader, err := s.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+		reader, err = s.Read("baz")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^qux$"))
+	})
+
+	It("should decrypt values encrypted with a previous key", func() {
+		Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+
+		keys.ActiveKeyID = "key-2"
+		reader, err := s.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+	})
+
+	It("should fail to decrypt values if the key is unknown", func() {
+		Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+
+		delete(keys.Keys, "key-1")
+		_, err := s.Read("foo")
+		Expect(err).To(MatchError(ContainSubstring(`encryption key "key-1" not found`)))
+	})
+
+	It("should fail to decrypt values moved to a different key", func() {
+		Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+
+		cm.BinaryData["baz"] = cm.BinaryData["foo"]
+		_, err := s.Read("baz")
+		Expect(err).To(MatchError(ContainSubstring("failed to decrypt")))
+	})
+
+	It("should encrypt compressed values", func() {
+		es := &terraformer.EncodingStore{Underlying: s, Encoding: terraformer.EncodingGzip}
+		Expect(es.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+		Expect(es.Store("baz", bytes.NewBufferString("qux"))).To(Succeed())
+		Expect(cm.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncoding, terraformer.EncodingGzip))
+		Expect(cm.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-1"))
+
+		reader, err := es.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+	})
+
+	It("should return unencrypted values as is", func() {
+		cm.Data["foo"] = "bar"
+		reader, err := s.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+	})
+
+	It("should store values unencrypted if there is no active key", func() {
+		cm.Annotations = map[string]string{
+			terraformer.AnnotationEncryptionKeyID:   "key-1",
+			terraformer.AnnotationEncryptionDataKey: "foo",
+		}
+		keys.ActiveKeyID = ""
+
+		Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue("foo", "bar"))
+		Expect(cm.Annotations).To(BeEmpty())
+	})
+})
+
+var _ = Describe("#LoadEncryptionKeys", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "tf-keys-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+	})
+
+	It("should load raw and base64 encoded keys and skip hidden files", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "key-1"), bytes.Repeat([]byte{1}, 32), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "key-2"), []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))+"\n"), 0600)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(dir, "..data"), 0700)).To(Succeed())
+
+		keys, err := terraformer.LoadEncryptionKeys(dir, "key-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys.ActiveKeyID).To(Equal("key-2"))
+		Expect(keys.Keys).To(Equal(map[string][]byte{
+			"key-1": bytes.Repeat([]byte{1}, 32),
+			"key-2": bytes.Repeat([]byte{2}, 32),
+		}))
+	})
+
+	It("should fail if a key has an invalid size", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "key-1"), []byte("too short"), 0600)).To(Succeed())
+
+		_, err := terraformer.LoadEncryptionKeys(dir, "")
+		Expect(err).To(MatchError(ContainSubstring("32 byte key")))
+	})
+
+	It("should fail if the active key doesn't exist", func() {
+		_, err := terraformer.LoadEncryptionKeys(dir, "key-1")
+		Expect(err).To(MatchError(ContainSubstring(`active encryption key "key-1" not found`)))
+	})
+})
+
+var _ = Describe("Terraformer State Encryption", func() {
+	var (
+		config   *terraformer.Config
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+
+		newTerraformer func() *terraformer.Terraformer
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		config = &terraformer.Config{
+			Namespace:                  testObjs.Namespace,
+			ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+			StateConfigMapName:         testObjs.StateConfigMap.Name,
+			VariablesSecretName:        testObjs.VariablesSecret.Name,
+			RESTConfig:                 restConfig,
+			CompressState:              true,
+			StateEncryptionKeys: &terraformer.EncryptionKeys{
+				ActiveKeyID: "key-1",
+				Keys: map[string][]byte{
+					"key-1": bytes.Repeat([]byte{1}, 32),
+					"key-2": bytes.Repeat([]byte{2}, 32),
+				},
+			},
+		}
+
+		newTerraformer = func() *terraformer.Terraformer {
+			tf, err := terraformer.NewTerraformer(config, runtimelog.Log, paths, clock.RealClock{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tf.EnsureTFDirs()).To(Succeed())
+			return tf
+		}
+	})
+
+	AfterEach(func() {
+		testutils.RunCleanupActions()
+	})
+
+	It("should store encrypted state and fetch it again", func() {
+		tf := newTerraformer()
+
+		stateContents := "secret state"
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		testObjs.Refresh()
+		Expect(testObjs.StateConfigMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-1"))
+		Expect(testObjs.StateConfigMap.BinaryData[testutils.StateKey]).NotTo(ContainSubstring(stateContents))
+
+		Expect(os.WriteFile(paths.StatePath, nil, 0644)).To(Succeed())
+		Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+		Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(stateContents)))
+	})
+
+	Describe("#RekeyState", func() {
+		It("should re-encrypt the state with the active key", func() {
+			stateContents := "secret state"
+			Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+			Expect(newTerraformer().StoreState(ctx)).To(Succeed())
+
+			config.StateEncryptionKeys.ActiveKeyID = "key-2"
+			tf := newTerraformer()
+			Expect(tf.RekeyState(ctx)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-2"))
+
+			By("removing the previous key")
+			delete(config.StateEncryptionKeys.Keys, "key-1")
+			Expect(os.WriteFile(paths.StatePath, nil, 0644)).To(Succeed())
+			Expect(newTerraformer().FetchConfigAndState(ctx)).To(Succeed())
+			Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(stateContents)))
+		})
+
+		It("should re-encrypt the state history with the active key", func() {
+			config.StateHistoryLimit = 2
+			tf := newTerraformer()
+			shutdownWorker := tf.StartStateUpdateWorker()
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"serial":1,"lineage":"secret"}`), 0644)).To(Succeed())
+			Expect(tf.TriggerAndWaitForFinalStateUpdate()).To(Succeed())
+			shutdownWorker()
+
+			config.StateEncryptionKeys.ActiveKeyID = "key-2"
+			Expect(newTerraformer().RekeyState(ctx)).To(Succeed())
+
+			history := &corev1.ConfigMapList{}
+			Expect(testClient.List(ctx, history, client.InNamespace(testObjs.Namespace), client.MatchingLabels{
+				terraformer.LabelStateHistoryOf: testObjs.StateConfigMap.Name,
+			})).To(Succeed())
+			Expect(history.Items).To(ConsistOf(
+				HaveField("ObjectMeta.Annotations", HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-2")),
+			))
+
+			By("rolling back without the previous key")
+			delete(config.StateEncryptionKeys.Keys, "key-1")
+			Expect(newTerraformer().RollbackState(ctx, 1)).To(Succeed())
+		})
+
+		It("should encrypt an unencrypted state", func() {
+			Expect(newTerraformer().RekeyState(ctx)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-1"))
+			Expect(testObjs.StateConfigMap.Data).NotTo(HaveKey(testutils.StateKey))
+		})
+
+		It("should fail without an active key", func() {
+			config.StateEncryptionKeys.ActiveKeyID = ""
+			Expect(newTerraformer().RekeyState(ctx)).To(MatchError(ContainSubstring("no active encryption key")))
+		})
+	})
+})
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errStaleState is returned if storing a state is refused, because it would overwrite a newer or unrelated state.
+var errStaleState = errors.New("refusing to overwrite stored state")
+
+// storedState holds the revision of the state, that is currently stored in the state object, as known by terraformer.
+type storedState struct {
+	// metadata of the stored state, nil if the stored state is empty or not a valid terraform state
+	metadata *stateMetadata
+	// resourceVersion of the state object, which is used for detecting concurrent writes
+	resourceVersion string
+	// sha256 is the hex-encoded checksum of the stored state, which is used for skipping redundant updates
+	sha256 string
+	// configSHA256 is the checksum of the config recorded on the state object, which has to be updated even if the state
+	// is unchanged
+	configSHA256 string
+}
+
+// newStoredState returns the storedState for the given state and state object, which is nil if it doesn't exist.
+func newStoredState(state []byte, obj client.Object) *storedState {
+	s := &storedState{}
+	if obj != nil {
+		// missing state objects have to be created, even for empty states
+		s.resourceVersion = obj.GetResourceVersion()
+		s.sha256 = stateChecksum(state)
+		s.configSHA256 = obj.GetAnnotations()[AnnotationConfigSHA256]
+	}
+	if len(state) > 0 {
+		// invalid states can't be guarded, they are overwritten by the next state
+		s.metadata, _ = parseStateMetadata(state)
+	}
+	return s
+}
+
+// checkStateRevision checks whether the given state may overwrite the stored state, i.e. whether it has the same
+// lineage and at least the same serial. Other states are rejected unless ForceStateUpdate is configured.
+// It returns the metadata of the given state.
+func (t *Terraformer) checkStateRevision(log logr.Logger, state []byte) (*stateMetadata, error) {
+	incoming := newStoredState(state, nil).metadata
+	stored := t.storedState.metadata
+	if incoming == nil || stored == nil {
+		return incoming, nil
+	}
+
+	var err error
+	switch {
+	case stored.Lineage != "" && incoming.Lineage != stored.Lineage:
+		err = fmt.Errorf("%w: lineage %q of the state doesn't match lineage %q of the stored state", errStaleState, incoming.Lineage, stored.Lineage)
+	case incoming.Serial < stored.Serial:
+		err = fmt.Errorf("%w: serial %d of the state is lower than serial %d of the stored state", errStaleState, incoming.Serial, stored.Serial)
+	}
+
+	if err != nil && t.config.ForceStateUpdate {
+		log.Info("overwriting stored state as forced", "reason", err.Error())
+		return incoming, nil
+	}
+	return incoming, err
+}
+
+// refreshStoredState reads the revision of the state, that is currently stored in the state object. It has to be
+// called with the stateMutex held.
+func (t *Terraformer) refreshStoredState(ctx context.Context) error {
+	state, obj, chunked, err := t.readStateObject(ctx, t.config.StateConfigMapName)
+	if err != nil {
+		return err
+	}
+	t.storedState = newStoredState(state, obj)
+	t.stateChunked = chunked
+	return nil
+}
+
+// updateStoredResourceVersion updates the resourceVersion of the stored state after terraformer patched or created the
+// given object from the given resourceVersion, if it is the state object (e.g. for adding finalizers or annotations).
+// Thereby, the next state update doesn't run into a conflict. The resourceVersion is only updated if the object was
+// patched from the known revision, otherwise concurrent writes of the state would go unnoticed. It has to be called with
+// the stateMutex held.
+func (t *Terraformer) updateStoredResourceVersion(oldResourceVersion string, obj client.Object) {
+	if _, isSecret := obj.(*corev1.Secret); isSecret != (t.stateKind() == StateKindSecret) || obj.GetName() != t.config.StateConfigMapName {
+		return
+	}
+	if t.storedState == nil || t.storedState.resourceVersion != oldResourceVersion {
+		return
+	}
+	t.storedState.resourceVersion = obj.GetResourceVersion()
+}
+
+// stateChecksum returns the hex-encoded sha256 checksum of the given state.
+func stateChecksum(state []byte) string {
+	sum := sha256.Sum256(state)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,249 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("Terraformer State Guard", func() {
+	const storedState = `{"lineage":"foo","serial":5}`
+
+	var (
+		config   *terraformer.Config
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+
+		newTerraformer func() *terraformer.Terraformer
+		getState       func() string
+		setState       func(state string)
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		config = &terraformer.Config{
+			Namespace:                  testObjs.Namespace,
+			ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+			StateConfigMapName:         testObjs.StateConfigMap.Name,
+			VariablesSecretName:        testObjs.VariablesSecret.Name,
+			RESTConfig:                 restConfig,
+		}
+
+		newTerraformer = func() *terraformer.Terraformer {
+			tf, err := terraformer.NewTerraformer(config, runtimelog.Log, paths, clock.RealClock{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tf.EnsureTFDirs()).To(Succeed())
+			return tf
+		}
+
+		getState = func() string {
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			return configMap.Data[testutils.StateKey]
+		}
+
+		setState = func(state string) {
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			configMap.Data[testutils.StateKey] = state
+			Expect(testClient.Update(ctx, configMap)).To(Succeed())
+		}
+
+		setState(storedState)
+	})
+
+	AfterEach(func() {
+		testutils.RunCleanupActions()
+	})
+
+	Context("state was fetched", func() {
+		var tf *terraformer.Terraformer
+
+		BeforeEach(func() {
+			tf = newTerraformer()
+			Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+		})
+
+		It("should store states with a higher serial", func() {
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":6}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(getState()).To(Equal(`{"lineage":"foo","serial":6}`))
+
+			By("storing the next state")
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":7}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(getState()).To(Equal(`{"lineage":"foo","serial":7}`))
+		})
+
+		It("should skip updates of unchanged states", func() {
+			By("recording the checksum of the config")
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(getState()).To(Equal(storedState))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			Expect(configMap.Annotations).To(HaveKey(terraformer.AnnotationConfigSHA256))
+			resourceVersion := configMap.ResourceVersion
+
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			Expect(configMap.ResourceVersion).To(Equal(resourceVersion))
+		})
+
+		It("should store the checksum of the state", func() {
+			state := `{"lineage":"foo","serial":6}`
+			Expect(os.WriteFile(paths.StatePath, []byte(state), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			sum := sha256.Sum256([]byte(state))
+			Expect(configMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationStateSHA256, hex.EncodeToString(sum[:])))
+			resourceVersion := configMap.ResourceVersion
+
+			By("skipping the update of the stored state")
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			Expect(configMap.ResourceVersion).To(Equal(resourceVersion))
+		})
+
+		It("should store the checksum of the config, that the state was produced with", func() {
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":6}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			Expect(configMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationConfigSHA256, MatchRegexp("^[0-9a-f]{64}$")))
+			configSHA256 := configMap.Annotations[terraformer.AnnotationConfigSHA256]
+
+			By("storing the next state with a changed config")
+			testObjs.Refresh()
+			testObjs.ConfigurationConfigMap.Data[testutils.ConfigMainKey] += "\n# changed"
+			Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+			tf = newTerraformer()
+			Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":7}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			Expect(configMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationConfigSHA256, Not(Equal(configSHA256))))
+		})
+
+		It("should update the checksum of the config if the state is unchanged", func() {
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":6}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			configSHA256 := configMap.Annotations[terraformer.AnnotationConfigSHA256]
+
+			By("storing the unchanged state with a changed config")
+			testObjs.Refresh()
+			testObjs.ConfigurationConfigMap.Data[testutils.ConfigMainKey] += "\n# changed"
+			Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+			tf = newTerraformer()
+			Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			Expect(configMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationConfigSHA256, Not(Equal(configSHA256))))
+			Expect(configMap.Data).To(HaveKeyWithValue(testutils.StateKey, `{"lineage":"foo","serial":6}`))
+
+			By("storing the next state without conflicts")
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":7}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(getState()).To(Equal(`{"lineage":"foo","serial":7}`))
+		})
+
+		It("should store states with the same serial", func() {
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":5,"outputs":{}}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(getState()).To(Equal(`{"lineage":"foo","serial":5,"outputs":{}}`))
+		})
+
+		It("should reject states with a lower serial", func() {
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":4}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(MatchError(ContainSubstring("serial 4 of the state is lower than serial 5")))
+			Expect(getState()).To(Equal(storedState))
+		})
+
+		It("should reject states with a different lineage", func() {
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"bar","serial":6}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(MatchError(ContainSubstring(`lineage "bar" of the state doesn't match lineage "foo"`)))
+			Expect(getState()).To(Equal(storedState))
+		})
+
+		It("should reject states older than a concurrently stored state", func() {
+			setState(`{"lineage":"foo","serial":7}`)
+
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":6}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(MatchError(ContainSubstring("serial 6 of the state is lower than serial 7")))
+			Expect(getState()).To(Equal(`{"lineage":"foo","serial":7}`))
+		})
+
+		It("should store the state if only the metadata of the state object was modified concurrently", func() {
+			configMap := &corev1.ConfigMap{}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), configMap)).To(Succeed())
+			configMap.Labels = map[string]string{"foo": "bar"}
+			Expect(testClient.Update(ctx, configMap)).To(Succeed())
+
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":6}`), 0644)).To(Succeed())
+			Expect(tf.StoreState(ctx)).To(Succeed())
+			Expect(getState()).To(Equal(`{"lineage":"foo","serial":6}`))
+		})
+
+		It("should fail the final state update without retrying if the state is rejected", func() {
+			shutdownWorker := tf.StartStateUpdateWorker()
+			defer shutdownWorker()
+
+			Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"foo","serial":4}`), 0644)).To(Succeed())
+			Expect(tf.TriggerAndWaitForFinalStateUpdate()).To(MatchError(ContainSubstring("refusing to overwrite stored state")))
+			Expect(getState()).To(Equal(storedState))
+		})
+	})
+
+	It("should overwrite the stored state if forced", func() {
+		config.ForceStateUpdate = true
+		tf := newTerraformer()
+		Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+
+		Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"bar","serial":1}`), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+		Expect(getState()).To(Equal(`{"lineage":"bar","serial":1}`))
+	})
+
+	It("should not check states if the state wasn't fetched", func() {
+		tf := newTerraformer()
+
+		Expect(os.WriteFile(paths.StatePath, []byte(`{"lineage":"bar","serial":1}`), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+		Expect(getState()).To(Equal(`{"lineage":"bar","serial":1}`))
+	})
+})
@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelStateHistoryOf is the label on state history objects holding the name of the state object they belong to.
+	LabelStateHistoryOf = "terraformer.gardener.cloud/state-history-of"
+	// LabelStateSerial is the label on state history objects holding the serial of the state revision.
+	LabelStateSerial = "terraformer.gardener.cloud/state-serial"
+	// LabelStateLineage is the label on state history objects holding the lineage of the state revision.
+	LabelStateLineage = "terraformer.gardener.cloud/state-lineage"
+	// LabelStateTimestamp is the label on state history objects holding the unix timestamp, at which the state
+	// revision was recorded.
+	LabelStateTimestamp = "terraformer.gardener.cloud/state-timestamp"
+	// LabelStateCommand is the label on state history objects holding the command, that produced the state revision.
+	LabelStateCommand = "terraformer.gardener.cloud/state-command"
+
+	// rollbackCommand is recorded as the command of state revisions, that were replaced by a rollback.
+	rollbackCommand = "rollback"
+)
+
+// stateMetadata holds the fields of a terraform state identifying a state revision.
+type stateMetadata struct {
+	Serial  int64  `json:"serial"`
+	Lineage string `json:"lineage"`
+}
+
+// parseStateMetadata returns the serial and lineage of the given state.
+func parseStateMetadata(state []byte) (*stateMetadata, error) {
+	metadata := &stateMetadata{}
+	if err := json.Unmarshal(state, metadata); err != nil {
+		return nil, fmt.Errorf("could not unmarshal terraform state from JSON: %w", err)
+	}
+	return metadata, nil
+}
+
+// storeStateHistory records the state file as a new revision in the state history and deletes the oldest revisions
+// exceeding the configured limit. It doesn't do anything if the state history is disabled, the state is empty or it
+// matches the newest revision.
+func (t *Terraformer) storeStateHistory(ctx context.Context, command string) error {
+	if t.config.StateHistoryLimit <= 0 {
+		return nil
+	}
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, stateUpdateTimeout)
+	defer cancel()
+
+	state, err := os.ReadFile(t.paths.StatePath)
+	if err != nil {
+		return err
+	}
+	return t.recordStateRevision(ctx, state, command)
+}
+
+func (t *Terraformer) recordStateRevision(ctx context.Context, state []byte, command string) error {
+	log := t.stepLogger("storeStateHistory")
+
+	if len(state) == 0 {
+		log.V(1).Info("state is empty, skipping state history")
+		return nil
+	}
+	metadata, err := parseStateMetadata(state)
+	if err != nil {
+		return err
+	}
+
+	// runs, that didn't change the state (e.g. plan), must not evict older revisions by recording the same state again
+	revisions, err := t.listStateHistory(ctx)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(state)
+	if len(revisions) > 0 && revisions[0].GetAnnotations()[AnnotationStateSHA256] == hex.EncodeToString(sum[:]) {
+		log.V(1).Info("state matches the newest state revision, skipping state history", "revision", revisions[0].GetName())
+		return nil
+	}
+
+	// the serial isn't necessarily unique, e.g. after a rollback, so the revision is identified by its contents as well
+	obj := t.newStateObject(fmt.Sprintf("%s-history-%d-%s", t.config.StateConfigMapName, metadata.Serial, hex.EncodeToString(sum[:])[:10]))
+	obj.Object().SetLabels(map[string]string{
+		LabelStateHistoryOf: t.config.StateConfigMapName,
+		LabelStateSerial:    strconv.FormatInt(metadata.Serial, 10),
+		LabelStateLineage:   metadata.Lineage,
+		LabelStateTimestamp: strconv.FormatInt(t.clock.Now().Unix(), 10),
+		LabelStateCommand:   command,
+	})
+
+	log.Info("recording state revision", "revision", obj.Object().GetName(), "serial", metadata.Serial, "lineage", metadata.Lineage)
+	if err := t.storeState(ctx, obj, bytes.NewReader(state)); err != nil {
+		return fmt.Errorf("failed to store state revision: %w", err)
+	}
+
+	return t.pruneStateHistory(ctx, log)
+}
+
+// pruneStateHistory deletes the oldest state revisions exceeding the configured limit.
+func (t *Terraformer) pruneStateHistory(ctx context.Context, log logr.Logger) error {
+	revisions, err := t.listStateHistory(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := t.config.StateHistoryLimit; i < len(revisions); i++ {
+		revision := revisions[i]
+		log.V(1).Info("deleting old state revision", "revision", revision.GetName())
+		if err := t.client.Delete(ctx, revision); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete old state revision %q: %w", revision.GetName(), err)
+		}
+		if err := t.cleanupStateChunks(ctx, log, revision.GetName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listStateHistory returns all state revisions matching the given labels, newest first.
+func (t *Terraformer) listStateHistory(ctx context.Context, labels ...client.MatchingLabels) ([]client.Object, error) {
+	list := t.newStateObjectList()
+	opts := []client.ListOption{client.InNamespace(t.config.Namespace), client.MatchingLabels{LabelStateHistoryOf: t.config.StateConfigMapName}}
+	for _, l := range labels {
+		opts = append(opts, l)
+	}
+	if err := t.client.List(ctx, list, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list state history: %w", err)
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		if revision, ok := item.(client.Object); ok {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	labelInt := func(obj client.Object, label string) int64 {
+		value, _ := strconv.ParseInt(obj.GetLabels()[label], 10, 64)
+		return value
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		if ti, tj := labelInt(revisions[i], LabelStateTimestamp), labelInt(revisions[j], LabelStateTimestamp); ti != tj {
+			return ti > tj
+		}
+		return labelInt(revisions[i], LabelStateSerial) > labelInt(revisions[j], LabelStateSerial)
+	})
+	return revisions, nil
+}
+
+// RollbackState restores the most recent state revision with the given serial from the state history into the state
+// object. The replaced state is recorded in the state history before, so that the rollback can be undone.
+// The lease is held during the rollback.
+func (t *Terraformer) RollbackState(ctx context.Context, serial int64) error {
+	return t.withLease(ctx, func(ctx context.Context) error {
+		return t.rollbackState(ctx, serial)
+	})
+}
+
+func (t *Terraformer) rollbackState(ctx context.Context, serial int64) error {
+	log := t.stepLogger("RollbackState")
+
+	revisions, err := t.listStateHistory(ctx, client.MatchingLabels{LabelStateSerial: strconv.FormatInt(serial, 10)})
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return fmt.Errorf("no state revision with serial %d found", serial)
+	}
+	revision := revisions[0]
+
+	state, err := t.readState(ctx, revision.GetName())
+	if err != nil {
+		return fmt.Errorf("failed to read state revision %q: %w", revision.GetName(), err)
+	}
+
+	currentState, _, chunked, err := t.readStateObject(ctx, t.config.StateConfigMapName)
+	if err != nil {
+		return err
+	}
+	// the manifest and the chunks of a chunked state have to be removed, if the restored state fits into a single object
+	t.stateMutex.Lock()
+	t.stateChunked = chunked
+	t.stateMutex.Unlock()
+	if bytes.Equal(currentState, state) {
+		log.Info("state already matches the state revision, nothing to do", "revision", revision.GetName())
+		return nil
+	}
+	if t.config.StateHistoryLimit > 0 {
+		if err := t.recordStateRevision(ctx, currentState, rollbackCommand); err != nil {
+			return err
+		}
+	}
+
+	log.Info("restoring state revision", "revision", revision.GetName(), "serial", serial, "lineage", revision.GetLabels()[LabelStateLineage])
+	if err := t.storeState(ctx, t.newStateObject(t.config.StateConfigMapName), bytes.NewReader(state)); err != nil {
+		return err
+	}
+
+	log.Info("successfully restored state revision")
+	return nil
+}
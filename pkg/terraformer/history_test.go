@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("Terraformer State History", func() {
+	const historyLimit = 2
+
+	var (
+		tf       *terraformer.Terraformer
+		config   *terraformer.Config
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+
+		listHistory   func() []corev1.ConfigMap
+		finalUpdate   func(serial int)
+		stateAtSerial func(serial int) string
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		config = &terraformer.Config{
+			Namespace:                  testObjs.Namespace,
+			ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+			StateConfigMapName:         testObjs.StateConfigMap.Name,
+			VariablesSecretName:        testObjs.VariablesSecret.Name,
+			RESTConfig:                 restConfig,
+			StateHistoryLimit:          historyLimit,
+			StateChunkSize:             1024,
+		}
+		tf, err = terraformer.NewTerraformer(config, runtimelog.Log, paths, clock.RealClock{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tf.EnsureTFDirs()).To(Succeed())
+
+		shutdownWorker := tf.StartStateUpdateWorker()
+		DeferCleanup(shutdownWorker)
+
+		listHistory = func() []corev1.ConfigMap {
+			list := &corev1.ConfigMapList{}
+			Expect(testClient.List(ctx, list, client.InNamespace(testObjs.Namespace), client.MatchingLabels{
+				terraformer.LabelStateHistoryOf: testObjs.StateConfigMap.Name,
+			})).To(Succeed())
+			return list.Items
+		}
+
+		stateAtSerial = func(serial int) string {
+			return fmt.Sprintf(`{"serial":%d,"lineage":"00000000-1111-2222-3333-444444444444"}`, serial)
+		}
+
+		finalUpdate = func(serial int) {
+			Expect(os.WriteFile(paths.StatePath, []byte(stateAtSerial(serial)), 0644)).To(Succeed())
+			Expect(tf.TriggerAndWaitForFinalStateUpdate()).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		testutils.RunCleanupActions()
+	})
+
+	It("should record state revisions with their serial and lineage", func() {
+		finalUpdate(1)
+
+		history := listHistory()
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].Labels).To(HaveKeyWithValue(terraformer.LabelStateSerial, "1"))
+		Expect(history[0].Labels).To(HaveKeyWithValue(terraformer.LabelStateLineage, "00000000-1111-2222-3333-444444444444"))
+		Expect(history[0].Labels).To(HaveKey(terraformer.LabelStateTimestamp))
+		Expect(history[0].Data).To(HaveKeyWithValue(testutils.StateKey, stateAtSerial(1)))
+	})
+
+	It("should only keep the configured number of revisions", func() {
+		finalUpdate(1)
+		finalUpdate(2)
+		finalUpdate(3)
+
+		Expect(listHistory()).To(ConsistOf(
+			HaveField("ObjectMeta.Labels", HaveKeyWithValue(terraformer.LabelStateSerial, "2")),
+			HaveField("ObjectMeta.Labels", HaveKeyWithValue(terraformer.LabelStateSerial, "3")),
+		))
+	})
+
+	It("should not record the state again if it matches the newest revision", func() {
+		finalUpdate(1)
+		finalUpdate(2)
+		revision := listHistory()[0]
+
+		finalUpdate(2)
+
+		Expect(listHistory()).To(ConsistOf(
+			HaveField("ObjectMeta.Labels", HaveKeyWithValue(terraformer.LabelStateSerial, "1")),
+			HaveField("ObjectMeta.ResourceVersion", revision.ResourceVersion),
+		))
+	})
+
+	Describe("#RollbackState", func() {
+		It("should restore the state revision with the given serial", func() {
+			finalUpdate(1)
+			finalUpdate(2)
+
+			Expect(tf.RollbackState(ctx, 1)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateAtSerial(1)))
+
+			By("rolling back the rollback")
+			Expect(tf.RollbackState(ctx, 2)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateAtSerial(2)))
+		})
+
+		It("should remove the chunks of the replaced state", func() {
+			finalUpdate(1)
+			largeState := fmt.Sprintf(`{"serial":2,"lineage":"00000000-1111-2222-3333-444444444444","padding":%q}`, strings.Repeat("x", 4096))
+			Expect(os.WriteFile(paths.StatePath, []byte(largeState), 0644)).To(Succeed())
+			Expect(tf.TriggerAndWaitForFinalStateUpdate()).To(Succeed())
+
+			listChunks := func() []corev1.ConfigMap {
+				list := &corev1.ConfigMapList{}
+				Expect(testClient.List(ctx, list, client.InNamespace(testObjs.Namespace), client.MatchingLabels{
+					terraformer.LabelStateChunkOf: testObjs.StateConfigMap.Name,
+				})).To(Succeed())
+				return list.Items
+			}
+			Expect(listChunks()).NotTo(BeEmpty())
+
+			// a new terraformer doesn't know whether the stored state is chunked
+			rollbackTF, err := terraformer.NewTerraformer(config, runtimelog.Log, paths, clock.RealClock{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rollbackTF.RollbackState(ctx, 1)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateAtSerial(1)))
+			Expect(testObjs.StateConfigMap.Data).NotTo(HaveKey(testutils.StateKey + ".manifest"))
+			Expect(listChunks()).To(BeEmpty())
+		})
+
+		It("should fail if there is no revision with the given serial", func() {
+			finalUpdate(1)
+
+			Expect(tf.RollbackState(ctx, 5)).To(MatchError(ContainSubstring("no state revision with serial 5")))
+		})
+	})
+})
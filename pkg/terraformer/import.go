@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/gardener/terraformer/pkg/utils"
+)
+
+// DefaultImportConfigMapKey is the default key of the import list in the import ConfigMap.
+const DefaultImportConfigMapKey = "imports"
+
+// resourceImport is a single entry of the import list.
+type resourceImport struct {
+	// Address is the address of the resource in the terraform config, e.g. `aws_vpc.main`.
+	Address string
+	// ID is the provider specific ID of the existing resource, e.g. `vpc-1234`.
+	ID string
+}
+
+// importResources imports all resources of the import list with `terraform import`, which are not in the state yet.
+// It continues with the next resource if an import fails and returns an error listing all failed imports.
+func (t *Terraformer) importResources(ctx context.Context) error {
+	log := t.stepLogger("importResources")
+
+	imports, err := t.fetchImports(ctx)
+	if err != nil {
+		return err
+	}
+
+	addresses, err := t.stateResourceAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		imported, skipped int
+		allErrs           = &multierror.Error{
+			ErrorFormat: utils.NewErrorFormatFuncWithPrefix("failed to import resources"),
+		}
+	)
+	for _, resource := range imports {
+		resourceLog := log.WithValues("address", resource.Address, "id", resource.ID)
+
+		if _, ok := addresses[resource.Address]; ok {
+			resourceLog.Info("resource is already in state, skipping import")
+			skipped++
+			continue
+		}
+
+		if err := t.executeTerraform(ctx, Import, resource.Address, resource.ID); err != nil {
+			resourceLog.Error(err, "failed to import resource")
+			allErrs = multierror.Append(allErrs, fmt.Errorf("%s: %w", resource.Address, err))
+			continue
+		}
+
+		resourceLog.Info("successfully imported resource")
+		addresses[resource.Address] = struct{}{}
+		imported++
+	}
+
+	log.Info("finished importing resources", "imported", imported, "skipped", skipped, "failed", len(allErrs.Errors))
+	return allErrs.ErrorOrNil()
+}
+
+// fetchImports reads the import list from the import ConfigMap.
+func (t *Terraformer) fetchImports(ctx context.Context) ([]resourceImport, error) {
+	key := client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.ImportConfigMapName}
+	configMap := &corev1.ConfigMap{}
+	if err := t.client.Get(ctx, key, configMap); err != nil {
+		return nil, fmt.Errorf("failed to fetch import list: %w", err)
+	}
+
+	dataKey := t.config.ImportConfigMapKey
+	if len(dataKey) == 0 {
+		dataKey = DefaultImportConfigMapKey
+	}
+
+	data, ok := configMap.Data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in ConfigMap %q", dataKey, key)
+	}
+	return parseImports(data)
+}
+
+// parseImports parses the import list, which holds one `address=id` pair per line. Empty lines and lines starting with
+// `#` are ignored.
+func parseImports(data string) ([]resourceImport, error) {
+	var imports []resourceImport
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		address, id, ok := splitAddressPair(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d of the import list is not of the form address=id: %q", lineNumber, line)
+		}
+		imports = append(imports, resourceImport{Address: address, ID: id})
+	}
+
+	return imports, scanner.Err()
+}
+
+// splitAddressPair splits the given `address=value` pair. Equal signs in index keys of the address
+// (e.g. `aws_vpc.main["a=b"]`) are no separator.
+func splitAddressPair(pair string) (string, string, bool) {
+	separator, depth := -1, 0
+	for i, c := range pair {
+		if c == '[' {
+			depth++
+		} else if c == ']' {
+			depth--
+		} else if c == '=' && depth == 0 {
+			separator = i
+			break
+		}
+	}
+
+	if separator <= 0 || separator == len(pair)-1 {
+		return "", "", false
+	}
+	return strings.TrimSpace(pair[:separator]), strings.TrimSpace(pair[separator+1:]), true
+}
+
+// stateResourceAddresses returns the addresses of all resources in the state using `terraform state list`.
+func (t *Terraformer) stateResourceAddresses(ctx context.Context) (map[string]struct{}, error) {
+	output, err := t.captureTerraform(ctx, t.stepLogger("stateResourceAddresses"), StateList, t.stateArgs()...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing terraform %s: %w", StateList, err)
+	}
+
+	addresses := map[string]struct{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if address := strings.TrimSpace(scanner.Text()); len(address) > 0 {
+			addresses[address] = struct{}{}
+		}
+	}
+	return addresses, scanner.Err()
+}
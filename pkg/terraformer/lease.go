@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/gardener/terraformer/pkg/utils"
+)
+
+// LeasePolicy defines how terraformer deals with stale leases, i.e. leases that have not been renewed within their
+// lease duration, e.g. because the holding Pod crashed.
+type LeasePolicy string
+
+const (
+	// LeasePolicySteal takes over stale leases immediately.
+	LeasePolicySteal LeasePolicy = "Steal"
+	// LeasePolicyWait treats stale leases like held leases, i.e. terraformer waits for them to be released until the
+	// lease wait timeout and fails afterwards. Stale leases have to be deleted manually.
+	LeasePolicyWait LeasePolicy = "Wait"
+
+	// ExitCodeLeaseNotAcquired is the exit code of terraformer if the lease could not be acquired.
+	ExitCodeLeaseNotAcquired = 10
+)
+
+// errLeaseLost is returned if storing a state is refused, because the lease was lost and another terraformer might
+// operate on the state already.
+var errLeaseLost = errors.New("lease was lost, refusing to store the state")
+
+// AcquireLease acquires the Lease named after the state object, which ensures that only a single terraformer
+// operates on the state at a time. If another terraformer holds the lease, it waits for the lease to be released
+// until the configured wait timeout. Stale leases are handled according to the configured LeasePolicy.
+// If the lease can't be acquired, an error with ExitCodeLeaseNotAcquired is returned.
+// After acquiring the lease, it is renewed in the background. If the lease is lost, onLost is called and all further
+// state updates are refused. The lease is considered lost as well, if it was not renewed within its lease duration.
+// It returns a func, that stops renewing and releases the lease. If leases are disabled, nothing is done.
+func (t *Terraformer) AcquireLease(ctx context.Context, onLost func()) (func(), error) {
+	if t.config.LeaseDuration <= 0 {
+		return func() {}, nil
+	}
+
+	log := t.stepLogger("lease").WithValues("lease", client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.StateConfigMapName})
+	holder := leaseHolderIdentity()
+
+	log.Info("acquiring lease", "holder", holder)
+	lease, err := t.acquireLease(ctx, log, holder)
+	if err != nil {
+		return nil, utils.WithTerraformerExitCode{Code: ExitCodeLeaseNotAcquired, Underlying: err}
+	}
+	log.Info("successfully acquired lease")
+	t.leaseLost.Store(false)
+	t.leaseRenewTime.Store(lease.Spec.RenewTime.UnixNano())
+
+	var wg wait.Group
+	renewCtx, cancel := context.WithCancel(context.Background())
+	wg.StartWithContext(renewCtx, func(ctx context.Context) {
+		t.renewLease(ctx, log, lease, onLost)
+	})
+
+	return func() {
+		cancel()
+		wg.Wait()
+
+		// root context might have been cancelled already, use a new context for releasing the lease
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), stateUpdateTimeout)
+		defer releaseCancel()
+
+		log.Info("releasing lease")
+		if err := t.client.Delete(releaseCtx, lease, client.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion}); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "failed to release lease")
+		}
+		t.leaseRenewTime.Store(0)
+	}, nil
+}
+
+// withLease executes fn while holding the lease. The context passed to fn is cancelled if the lease is lost.
+func (t *Terraformer) withLease(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	releaseLease, err := t.AcquireLease(ctx, func() {
+		t.log.Info("lease was lost, stopping")
+		cancel()
+	})
+	if err != nil {
+		return err
+	}
+	defer releaseLease()
+
+	return fn(ctx)
+}
+
+// checkLease returns an error if the lease held by this terraformer was lost or was not renewed within its lease
+// duration, i.e. if another terraformer might have taken it over already. It returns nil if no lease is held.
+func (t *Terraformer) checkLease() error {
+	if t.leaseLost.Load() {
+		return errLeaseLost
+	}
+
+	renewTime := t.leaseRenewTime.Load()
+	if renewTime == 0 {
+		return nil
+	}
+	if sinceRenewal := t.clock.Since(time.Unix(0, renewTime)); sinceRenewal >= t.config.LeaseDuration {
+		t.leaseLost.Store(true)
+		return fmt.Errorf("%w: lease was not renewed for %s", errLeaseLost, sinceRenewal.Round(time.Second))
+	}
+	return nil
+}
+
+func (t *Terraformer) acquireLease(ctx context.Context, log logr.Logger, holder string) (*coordinationv1.Lease, error) {
+	timeout := t.clock.After(t.config.LeaseWaitTimeout)
+
+	for {
+		lease, err := t.tryAcquireLease(ctx, log, holder)
+		if err == nil && lease != nil {
+			return lease, nil
+		}
+		// conflicts mean, that another terraformer was faster, try again
+		if err != nil && !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to acquire lease: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout:
+			return nil, fmt.Errorf("timed out waiting for lease to be released after %s", t.config.LeaseWaitTimeout)
+		case <-t.clock.After(t.leaseRetryPeriod()):
+		}
+	}
+}
+
+// tryAcquireLease tries to acquire the lease once. It returns nil if the lease is held by someone else.
+func (t *Terraformer) tryAcquireLease(ctx context.Context, log logr.Logger, holder string) (*coordinationv1.Lease, error) {
+	now := metav1.NewMicroTime(t.clock.Now())
+
+	lease := &coordinationv1.Lease{}
+	if err := t.client.Get(ctx, client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.StateConfigMapName}, lease); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		lease.Namespace, lease.Name = t.config.Namespace, t.config.StateConfigMapName
+		lease.Spec = t.leaseSpec(holder, now)
+		return lease, t.client.Create(ctx, lease)
+	}
+
+	currentHolder := ptr.Deref(lease.Spec.HolderIdentity, "")
+	if currentHolder != "" {
+		if !t.leaseExpired(lease) {
+			log.Info("lease is held by another terraformer, waiting for it to be released", "holder", currentHolder)
+			return nil, nil
+		}
+		if t.config.LeasePolicy == LeasePolicyWait {
+			log.Info("lease of another terraformer is stale, waiting for it to be released", "holder", currentHolder)
+			return nil, nil
+		}
+		log.Info("taking over stale lease of another terraformer", "holder", currentHolder)
+	}
+
+	spec := t.leaseSpec(holder, now)
+	spec.LeaseTransitions = ptr.To(ptr.Deref(lease.Spec.LeaseTransitions, 0) + 1)
+	lease.Spec = spec
+	// update with optimistic locking, only one terraformer can win the race for the lease
+	return lease, t.client.Update(ctx, lease)
+}
+
+func (t *Terraformer) renewLease(ctx context.Context, log logr.Logger, lease *coordinationv1.Lease, onLost func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.clock.After(t.leaseRetryPeriod()):
+		}
+
+		renewed := lease.DeepCopy()
+		renewed.Spec.RenewTime = ptr.To(metav1.NewMicroTime(t.clock.Now()))
+		if err := t.client.Update(ctx, renewed); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				log.Error(err, "lost lease, stopping")
+				t.leaseLost.Store(true)
+				onLost()
+				return
+			}
+			// other terraformers may take over the lease once it was not renewed within its lease duration
+			if leaseErr := t.checkLease(); leaseErr != nil {
+				log.Error(err, "failed to renew lease within its lease duration, stopping")
+				onLost()
+				return
+			}
+			log.Error(err, "failed to renew lease, retrying")
+			continue
+		}
+		*lease = *renewed
+		t.leaseRenewTime.Store(renewed.Spec.RenewTime.UnixNano())
+		log.V(1).Info("renewed lease")
+	}
+}
+
+func (t *Terraformer) leaseSpec(holder string, now metav1.MicroTime) coordinationv1.LeaseSpec {
+	return coordinationv1.LeaseSpec{
+		HolderIdentity:       ptr.To(holder),
+		LeaseDurationSeconds: ptr.To(int32(t.config.LeaseDuration.Seconds())),
+		AcquireTime:          ptr.To(now),
+		RenewTime:            ptr.To(now),
+	}
+}
+
+// leaseExpired returns true if the lease has not been renewed within its lease duration.
+func (t *Terraformer) leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(t.clock.Now())
+}
+
+// leaseRetryPeriod returns the interval for renewing the lease and retrying to acquire it.
+func (t *Terraformer) leaseRetryPeriod() time.Duration {
+	return t.config.LeaseDuration / 3
+}
+
+// leaseHolderIdentity returns a unique identity of this terraformer, prefixed with the Pod name.
+func leaseHolderIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "terraformer"
+	}
+	return hostname + "_" + utilrand.String(8)
+}
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	"github.com/gardener/terraformer/pkg/utils"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("Terraformer Lease", func() {
+	var (
+		config   *terraformer.Config
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+		lease    *coordinationv1.Lease
+
+		newTerraformer func() *terraformer.Terraformer
+		createLease    func(renewTime time.Time)
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		config = &terraformer.Config{
+			Namespace:                  testObjs.Namespace,
+			ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+			StateConfigMapName:         testObjs.StateConfigMap.Name,
+			VariablesSecretName:        testObjs.VariablesSecret.Name,
+			RESTConfig:                 restConfig,
+			LeaseDuration:              3 * time.Second,
+			LeaseWaitTimeout:           100 * time.Millisecond,
+		}
+
+		newTerraformer = func() *terraformer.Terraformer {
+			tf, err := terraformer.NewTerraformer(config, runtimelog.Log, paths, clock.RealClock{})
+			Expect(err).NotTo(HaveOccurred())
+			return tf
+		}
+
+		lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: testObjs.StateConfigMap.Name}}
+
+		createLease = func(renewTime time.Time) {
+			lease.Spec = coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To("other-terraformer"),
+				LeaseDurationSeconds: ptr.To[int32](3),
+				RenewTime:            ptr.To(metav1.NewMicroTime(renewTime)),
+			}
+			Expect(testClient.Create(ctx, lease)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		testutils.RunCleanupActions()
+	})
+
+	It("should not do anything if leases are disabled", func() {
+		config.LeaseDuration = 0
+
+		release, err := newTerraformer().AcquireLease(ctx, func() {})
+		Expect(err).NotTo(HaveOccurred())
+		release()
+
+		Expect(apierrors.IsNotFound(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease))).To(BeTrue())
+	})
+
+	It("should acquire and release the lease", func() {
+		release, err := newTerraformer().AcquireLease(ctx, func() {})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease)).To(Succeed())
+		Expect(lease.Spec.HolderIdentity).NotTo(BeNil())
+		Expect(lease.Spec.LeaseDurationSeconds).To(PointTo(BeEquivalentTo(3)))
+
+		By("renewing the lease")
+		renewTime := lease.Spec.RenewTime.Time
+		Eventually(func(g Gomega) {
+			g.Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease)).To(Succeed())
+			g.Expect(lease.Spec.RenewTime.Time).To(BeTemporally(">", renewTime))
+		}, 3*time.Second, 100*time.Millisecond).Should(Succeed())
+
+		release()
+		Expect(apierrors.IsNotFound(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease))).To(BeTrue())
+	})
+
+	It("should fail with a distinct exit code if the lease is held by another terraformer", func() {
+		createLease(time.Now())
+
+		_, err := newTerraformer().AcquireLease(ctx, func() {})
+		Expect(err).To(MatchError(ContainSubstring("timed out waiting for lease")))
+
+		var exitCoder utils.ExitCoder
+		Expect(errors.As(err, &exitCoder)).To(BeTrue())
+		Expect(exitCoder.ExitCode()).To(Equal(terraformer.ExitCodeLeaseNotAcquired))
+	})
+
+	It("should acquire the lease once it was released by another terraformer", func() {
+		config.LeaseWaitTimeout = 10 * time.Second
+		createLease(time.Now())
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(500 * time.Millisecond)
+			Expect(testClient.Delete(ctx, lease.DeepCopy())).To(Succeed())
+		}()
+
+		release, err := newTerraformer().AcquireLease(ctx, func() {})
+		Expect(err).NotTo(HaveOccurred())
+		release()
+	})
+
+	It("should take over stale leases with the Steal policy", func() {
+		config.LeasePolicy = terraformer.LeasePolicySteal
+		createLease(time.Now().Add(-time.Minute))
+
+		release, err := newTerraformer().AcquireLease(ctx, func() {})
+		Expect(err).NotTo(HaveOccurred())
+		defer release()
+
+		Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease)).To(Succeed())
+		Expect(lease.Spec.HolderIdentity).NotTo(PointTo(Equal("other-terraformer")))
+		Expect(lease.Spec.LeaseTransitions).To(PointTo(BeEquivalentTo(1)))
+	})
+
+	It("should not take over stale leases with the Wait policy", func() {
+		config.LeasePolicy = terraformer.LeasePolicyWait
+		createLease(time.Now().Add(-time.Minute))
+
+		_, err := newTerraformer().AcquireLease(ctx, func() {})
+		Expect(err).To(MatchError(ContainSubstring("timed out waiting for lease")))
+	})
+
+	It("should call onLost if the lease was taken over", func() {
+		lost := make(chan struct{})
+		release, err := newTerraformer().AcquireLease(ctx, func() { close(lost) })
+		Expect(err).NotTo(HaveOccurred())
+		defer release()
+
+		Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease)).To(Succeed())
+		lease.Spec.HolderIdentity = ptr.To("other-terraformer")
+		Expect(testClient.Update(ctx, lease)).To(Succeed())
+
+		Eventually(lost, 3*time.Second).Should(BeClosed())
+	})
+
+	It("should refuse storing the state after the lease was taken over", func() {
+		tf := newTerraformer()
+		Expect(tf.EnsureTFDirs()).To(Succeed())
+		Expect(os.WriteFile(paths.StatePath, []byte("some state"), 0600)).To(Succeed())
+
+		lost := make(chan struct{})
+		release, err := tf.AcquireLease(ctx, func() { close(lost) })
+		Expect(err).NotTo(HaveOccurred())
+		defer release()
+
+		Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lease), lease)).To(Succeed())
+		lease.Spec.HolderIdentity = ptr.To("other-terraformer")
+		Expect(testClient.Update(ctx, lease)).To(Succeed())
+
+		Eventually(lost, 3*time.Second).Should(BeClosed())
+		Expect(tf.StoreState(ctx)).To(MatchError(ContainSubstring("lease was lost")))
+	})
+
+	It("should treat the lease as lost if it was not renewed within its lease duration", func() {
+		tf := newTerraformer()
+		tf.InjectClient(failingLeaseRenewalClient{testClient})
+		Expect(tf.EnsureTFDirs()).To(Succeed())
+		Expect(os.WriteFile(paths.StatePath, []byte("some state"), 0600)).To(Succeed())
+
+		lost := make(chan struct{})
+		release, err := tf.AcquireLease(ctx, func() { close(lost) })
+		Expect(err).NotTo(HaveOccurred())
+		defer release()
+
+		Expect(tf.StoreState(ctx)).To(Succeed())
+		Eventually(lost, 5*time.Second).Should(BeClosed())
+		Expect(tf.StoreState(ctx)).To(MatchError(ContainSubstring("lease was lost")))
+	})
+
+	It("should hold the lease while rolling back the state", func() {
+		createLease(time.Now())
+
+		err := newTerraformer().RollbackState(ctx, 1)
+		Expect(err).To(MatchError(ContainSubstring("timed out waiting for lease")))
+	})
+})
+
+// failingLeaseRenewalClient fails all updates of leases, e.g. like an unavailable API server.
+type failingLeaseRenewalClient struct {
+	client.Client
+}
+
+func (c failingLeaseRenewalClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, ok := obj.(*coordinationv1.Lease); ok {
+		return errors.New("fake")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
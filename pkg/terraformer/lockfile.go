@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tfLockFileKey is the name of terraform's provider dependency lock file in the config dir and the key in the provider
+// lock ConfigMap.
+const tfLockFileKey = ".terraform.lock.hcl"
+
+// restoreLockFile writes the lock file stored in the provider lock ConfigMap to the config dir, so that terraform init
+// installs the recorded provider versions and verifies them against the recorded hashes. If no lock file is stored,
+// a stale lock file of a previous run is removed. In strict mode, a lock file has to be stored or contained in the
+// config.
+func (t *Terraformer) restoreLockFile(ctx context.Context, log logr.Logger) error {
+	t.lockFile = nil
+
+	if len(t.config.ProviderLockConfigMapName) > 0 {
+		key := client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.ProviderLockConfigMapName}
+		log := log.WithValues("kind", "ConfigMap", "object", key)
+
+		if slices.Contains(t.configFiles, tfLockFileKey) {
+			return fmt.Errorf("%s is contained in the config, it can't be restored from ConfigMap %q as well", tfLockFileKey, key)
+		}
+
+		log.V(1).Info("fetching provider lock file")
+		obj := &ConfigMapStore{&corev1.ConfigMap{}}
+		if err := t.client.Get(ctx, key, obj.Object()); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		lockFile, err := readValue(obj, tfLockFileKey)
+		if ignoreKeyNotFound(err) != nil {
+			return fmt.Errorf("failed reading from ConfigMap %q: %w", key, err)
+		}
+		t.lockFile = lockFile
+
+		if len(lockFile) == 0 {
+			log.V(1).Info("no provider lock file stored, removing local lock file", "file", t.paths.LockFilePath)
+			if err := os.Remove(t.paths.LockFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		} else {
+			log.V(1).Info("copying provider lock file to file", "file", t.paths.LockFilePath)
+			if err := os.WriteFile(t.paths.LockFilePath, lockFile, 0600); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.config.StrictProviderLock {
+		if _, err := os.Stat(t.paths.LockFilePath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("strict provider lock requires %s to be stored in the provider lock ConfigMap or contained in the config", tfLockFileKey)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// storeLockFile stores the lock file written by terraform init in the provider lock ConfigMap, if it changed.
+func (t *Terraformer) storeLockFile(ctx context.Context) error {
+	if len(t.config.ProviderLockConfigMapName) == 0 {
+		return nil
+	}
+
+	log := t.stepLogger("storeLockFile")
+
+	lockFile, err := os.ReadFile(t.paths.LockFilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// terraform doesn't write a lock file if the config doesn't require any providers
+			log.V(1).Info("terraform didn't write a provider lock file")
+			return nil
+		}
+		return err
+	}
+	if bytes.Equal(lockFile, t.lockFile) {
+		log.V(1).Info("provider lock file is unchanged")
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace: t.config.Namespace,
+		Name:      t.config.ProviderLockConfigMapName,
+	}}
+	obj := &ConfigMapStore{configMap}
+	if err := obj.Store(tfLockFileKey, bytes.NewReader(lockFile)); err != nil {
+		return err
+	}
+
+	lockLog := log.WithValues("configMap", client.ObjectKeyFromObject(configMap))
+	if err := storeObject(ctx, lockLog, t.client, obj); err != nil {
+		return err
+	}
+	t.lockFile = lockFile
+
+	lockLog.Info("successfully stored provider lock file")
+	return nil
+}
+
+// lockFileArgs returns the arguments for terraform init, that prevent updating the lock file in strict mode.
+func (t *Terraformer) lockFileArgs() []string {
+	if t.config.StrictProviderLock {
+		return []string{"-lockfile=readonly"}
+	}
+	return nil
+}
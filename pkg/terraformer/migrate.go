@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// MigrateState moves the state from the state ConfigMap into the state Secret. After copying, the state is read back
+// from the Secret and compared to the original state. Only if both match, the ConfigMap and its chunks are deleted.
+// The terraformer finalizer is carried over to the Secret.
+// If the ConfigMap doesn't exist (anymore), there is nothing to migrate. Hence, the migration can be safely retried.
+// The lease is held during the migration.
+func (t *Terraformer) MigrateState(ctx context.Context) error {
+	return t.withLease(ctx, t.migrateState)
+}
+
+func (t *Terraformer) migrateState(ctx context.Context) error {
+	log := t.stepLogger("MigrateState")
+
+	if t.stateKind() != StateKindSecret {
+		return fmt.Errorf("state can only be migrated to a Secret, but state kind is %s", t.stateKind())
+	}
+
+	var (
+		name      = t.config.StateConfigMapName
+		source    = t.withStateKind(StateKindConfigMap)
+		configMap = source.newStateObject(name).Object()
+	)
+
+	if err := t.client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("state ConfigMap not found, nothing to migrate")
+			return nil
+		}
+		return err
+	}
+
+	state, err := source.readState(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read state from ConfigMap: %w", err)
+	}
+
+	// don't overwrite a state, which was stored in the Secret in the meantime
+	existingState, err := t.readState(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read state from Secret: %w", err)
+	}
+	if len(existingState) > 0 && !bytes.Equal(existingState, state) {
+		return fmt.Errorf("state Secret already contains a different state, refusing to overwrite it")
+	}
+
+	log.Info("copying state from ConfigMap to Secret")
+	if err := t.storeState(ctx, t.newStateObject(name), bytes.NewReader(state)); err != nil {
+		return fmt.Errorf("failed to store state in Secret: %w", err)
+	}
+
+	migratedState, err := t.readState(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read migrated state from Secret: %w", err)
+	}
+	if !bytes.Equal(migratedState, state) {
+		return fmt.Errorf("migrated state doesn't match the state in the ConfigMap, keeping the ConfigMap")
+	}
+
+	if controllerutil.ContainsFinalizer(configMap, TerraformerFinalizer) {
+		if err := t.updateObjectFinalizers(ctx, log, t.newStateObject(name).Object(), controllerutil.AddFinalizer); err != nil {
+			return fmt.Errorf("failed to add finalizer to state Secret: %w", err)
+		}
+	}
+
+	log.Info("deleting state ConfigMap")
+	if err := source.cleanupStateChunks(ctx, log, name); err != nil {
+		return err
+	}
+	if err := source.updateObjectFinalizers(ctx, log, configMap, controllerutil.RemoveFinalizer); err != nil {
+		return fmt.Errorf("failed to remove finalizer from state ConfigMap: %w", err)
+	}
+	if err := t.client.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete state ConfigMap: %w", err)
+	}
+
+	checksum := sha256.Sum256(state)
+	log.Info("successfully migrated state", "size", len(state), "sha256", hex.EncodeToString(checksum[:]))
+	return nil
+}
+
+// withStateKind returns a Terraformer sharing the client with this one, which stores the state in an object of the
+// given kind.
+func (t *Terraformer) withStateKind(kind StateKind) *Terraformer {
+	config := *t.config
+	config.StateKind = kind
+
+	return &Terraformer{
+		config: &config,
+		paths:  t.paths,
+		log:    t.log,
+		client: t.client,
+		clock:  t.clock,
+	}
+}
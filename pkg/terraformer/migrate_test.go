@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer_test
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	runtimelog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/gardener/terraformer/pkg/terraformer"
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+var _ = Describe("Terraformer State Secret", func() {
+	var (
+		config   *terraformer.Config
+		paths    *terraformer.PathSet
+		testObjs *testutils.TestObjects
+
+		newTerraformer func() *terraformer.Terraformer
+		stateSecret    func() *corev1.Secret
+	)
+
+	BeforeEach(func() {
+		baseDir, err := os.MkdirTemp("", "tf-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		var handle testutils.CleanupActionHandle
+		handle = testutils.AddCleanupAction(func() {
+			defer testutils.RemoveCleanupAction(handle)
+			Expect(os.RemoveAll(baseDir)).To(Succeed())
+		})
+
+		paths = terraformer.DefaultPaths().WithBaseDir(baseDir)
+
+		testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "")
+
+		config = &terraformer.Config{
+			Namespace:                  testObjs.Namespace,
+			ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+			StateConfigMapName:         testObjs.StateConfigMap.Name,
+			StateKind:                  terraformer.StateKindSecret,
+			VariablesSecretName:        testObjs.VariablesSecret.Name,
+			RESTConfig:                 restConfig,
+		}
+
+		newTerraformer = func() *terraformer.Terraformer {
+			tf, err := terraformer.NewTerraformer(config, runtimelog.Log, paths, clock.RealClock{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tf.EnsureTFDirs()).To(Succeed())
+			return tf
+		}
+
+		stateSecret = func() *corev1.Secret {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: testObjs.StateConfigMap.Name}}
+			Expect(testClient.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+			return secret
+		}
+	})
+
+	AfterEach(func() {
+		testutils.RunCleanupActions()
+	})
+
+	It("should store the state in a Secret and fetch it again", func() {
+		tf := newTerraformer()
+
+		stateContents := "state in secret"
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		Expect(stateSecret().Data).To(HaveKeyWithValue(testutils.StateKey, []byte(stateContents)))
+
+		Expect(os.WriteFile(paths.StatePath, nil, 0644)).To(Succeed())
+		Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+		Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(stateContents)))
+	})
+
+	It("should store large states in chunk Secrets", func() {
+		config.StateChunkSize = 16
+		tf := newTerraformer()
+
+		stateContents := strings.Repeat("large state ", 5)
+		Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+		Expect(tf.StoreState(ctx)).To(Succeed())
+
+		chunks := &corev1.SecretList{}
+		Expect(testClient.List(ctx, chunks, client.InNamespace(testObjs.Namespace), client.MatchingLabels{
+			terraformer.LabelStateChunkOf: testObjs.StateConfigMap.Name,
+		})).To(Succeed())
+		Expect(chunks.Items).To(HaveLen(4))
+
+		Expect(os.WriteFile(paths.StatePath, nil, 0644)).To(Succeed())
+		Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+		Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(stateContents)))
+	})
+
+	Describe("#MigrateState", func() {
+		var stateContents string
+
+		BeforeEach(func() {
+			stateContents = testObjs.StateConfigMap.Data[testutils.StateKey]
+
+			patch := client.MergeFrom(testObjs.StateConfigMap.DeepCopy())
+			controllerutil.AddFinalizer(testObjs.StateConfigMap, terraformer.TerraformerFinalizer)
+			Expect(testClient.Patch(ctx, testObjs.StateConfigMap, patch)).To(Succeed())
+		})
+
+		It("should move the state from the ConfigMap into the Secret", func() {
+			Expect(newTerraformer().MigrateState(ctx)).To(Succeed())
+
+			secret := stateSecret()
+			Expect(secret.Data).To(HaveKeyWithValue(testutils.StateKey, []byte(stateContents)))
+			Expect(secret.Finalizers).To(ConsistOf(terraformer.TerraformerFinalizer))
+
+			err := testClient.Get(ctx, client.ObjectKeyFromObject(testObjs.StateConfigMap), &corev1.ConfigMap{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue(), "state ConfigMap should be deleted")
+
+			By("running the migration again")
+			Expect(newTerraformer().MigrateState(ctx)).To(Succeed())
+			Expect(stateSecret().Data).To(HaveKeyWithValue(testutils.StateKey, []byte(stateContents)))
+		})
+
+		It("should not overwrite a different state in the Secret", func() {
+			Expect(testClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: testObjs.StateConfigMap.Name},
+				Data:       map[string][]byte{testutils.StateKey: []byte("newer state")},
+			})).To(Succeed())
+
+			Expect(newTerraformer().MigrateState(ctx)).To(MatchError(ContainSubstring("different state")))
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateContents))
+			Expect(stateSecret().Data).To(HaveKeyWithValue(testutils.StateKey, []byte("newer state")))
+		})
+
+		It("should fail if the state kind is not Secret", func() {
+			config.StateKind = terraformer.StateKindConfigMap
+			Expect(newTerraformer().MigrateState(ctx)).To(MatchError(ContainSubstring("state kind is ConfigMap")))
+		})
+	})
+})
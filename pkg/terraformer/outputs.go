@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// terraformOutput is a single output as returned by `terraform output -json`.
+type terraformOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// exportedOutput is the value of a single output, as it is stored in the outputs ConfigMap or Secret.
+type exportedOutput struct {
+	Type  json.RawMessage `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// exportOutputs reads the outputs with `terraform output -json` and stores the non-sensitive outputs in the outputs
+// ConfigMap and the sensitive outputs in the outputs Secret. Every output is stored under its own key as JSON object
+// holding its type and value. Outputs, that don't exist anymore, are removed from the objects.
+func (t *Terraformer) exportOutputs(ctx context.Context) error {
+	if len(t.config.OutputsConfigMapName) == 0 && len(t.config.OutputsSecretName) == 0 {
+		return nil
+	}
+
+	log := t.stepLogger("exportOutputs")
+
+	rawOutputs, err := t.captureTerraform(ctx, log, Output, append([]string{"-json"}, t.stateArgs()...)...)
+	if err != nil {
+		return fmt.Errorf("error executing terraform %s: %w", Output, err)
+	}
+
+	outputs := map[string]terraformOutput{}
+	if err := json.Unmarshal(rawOutputs, &outputs); err != nil {
+		return fmt.Errorf("failed to decode terraform outputs: %w", err)
+	}
+
+	var (
+		data          = map[string]string{}
+		sensitiveData = map[string][]byte{}
+	)
+	for name, output := range outputs {
+		value, err := json.Marshal(exportedOutput{Type: output.Type, Value: output.Value})
+		if err != nil {
+			return err
+		}
+
+		if output.Sensitive {
+			sensitiveData[name] = value
+		} else {
+			data[name] = string(value)
+		}
+	}
+
+	if len(t.config.OutputsConfigMapName) > 0 {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: t.config.Namespace, Name: t.config.OutputsConfigMapName}}
+		if err := t.writeOutputsObject(ctx, log, configMap, func() {
+			configMap.Data, configMap.BinaryData = data, nil
+		}); err != nil {
+			return fmt.Errorf("failed to store outputs: %w", err)
+		}
+	} else if len(data) > 0 {
+		log.Info("skipping non-sensitive outputs, as no outputs ConfigMap is configured", "count", len(data))
+	}
+
+	if len(t.config.OutputsSecretName) > 0 {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: t.config.Namespace, Name: t.config.OutputsSecretName}}
+		if err := t.writeOutputsObject(ctx, log, secret, func() {
+			secret.Data = sensitiveData
+		}); err != nil {
+			return fmt.Errorf("failed to store sensitive outputs: %w", err)
+		}
+	} else if len(sensitiveData) > 0 {
+		log.Info("skipping sensitive outputs, as no outputs Secret is configured", "count", len(sensitiveData))
+	}
+
+	log.Info("successfully exported outputs", "outputs", len(data), "sensitiveOutputs", len(sensitiveData))
+	return nil
+}
+
+// writeOutputsObject replaces the data of the given outputs object using setData and updates it. The object is
+// created with the terraformer finalizer if it doesn't exist.
+func (t *Terraformer) writeOutputsObject(ctx context.Context, log logr.Logger, obj client.Object, setData func()) error {
+	key := client.ObjectKeyFromObject(obj)
+	log = log.WithValues("object", key)
+
+	for i := 0; ; i++ {
+		if err := t.client.Get(ctx, key, obj); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+
+			log.V(1).Info("creating outputs object")
+			setData()
+			controllerutil.AddFinalizer(obj, TerraformerFinalizer)
+			return t.client.Create(ctx, obj)
+		}
+
+		log.V(1).Info("updating outputs object")
+		setData()
+		err := t.client.Update(ctx, obj)
+		if !apierrors.IsConflict(err) || i >= maxPatchRetries {
+			return err
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 
 // PathSet carries the set of file paths for terraform files and allows to set different paths in tests
 type PathSet struct {
-	// ConfigDir is the directory to hold the main terraform scripts (`main.tf` and `variables.tf`)
+	// ConfigDir is the directory to hold the terraform config files (e.g. `main.tf` and `variables.tf`)
 	ConfigDir string
 	// VarsDir is the directory to hold the terraform variables values file (`terraform.tfvars`)
 	VarsDir string
@@ -32,6 +32,10 @@ type PathSet struct {
 	VarsPath string
 	// StatePath is the complete path the the state file
 	StatePath string
+	// PlanPath is the complete path the the plan file created by the plan command
+	PlanPath string
+	// LockFilePath is the complete path the the provider dependency lock file written by terraform init
+	LockFilePath string
 }
 
 // DefaultPaths returns the default PathSet used in terraformer
@@ -45,6 +49,8 @@ func DefaultPaths() *PathSet {
 	}
 	p.VarsPath = path.Join(p.VarsDir, tfVarsKey)
 	p.StatePath = path.Join(p.StateDir, tfStateKey)
+	p.PlanPath = path.Join(p.ConfigDir, tfPlanKey)
+	p.LockFilePath = path.Join(p.ConfigDir, tfLockFileKey)
 
 	return p
 }
@@ -60,6 +66,8 @@ func (p *PathSet) WithBaseDir(baseDir string) *PathSet {
 		TerminationMessagePath: filepath.Join(baseDir, p.TerminationMessagePath),
 		VarsPath:               filepath.Join(baseDir, p.VarsPath),
 		StatePath:              filepath.Join(baseDir, p.StatePath),
+		PlanPath:               filepath.Join(baseDir, p.PlanPath),
+		LockFilePath:           filepath.Join(baseDir, p.LockFilePath),
 	}
 }
 
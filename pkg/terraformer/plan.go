@@ -0,0 +1,225 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/gardener/terraformer/pkg/utils"
+)
+
+const (
+	tfPlanKey     = "terraform.tfplan"
+	tfPlanJSONKey = "terraform.tfplan.json"
+
+	// LabelPlanOf is the label on plan Secrets holding the name of the state object, that the plan was created for.
+	LabelPlanOf = "terraformer.gardener.cloud/plan-of"
+	// AnnotationPlanPendingChanges is the annotation on plan Secrets, that records whether the plan contains changes.
+	AnnotationPlanPendingChanges = "terraformer.gardener.cloud/plan-pending-changes"
+	// AnnotationPlanStateSerial is the annotation on plan Secrets, that records the serial of the state, that the plan
+	// was created against. It is empty if the state was empty.
+	AnnotationPlanStateSerial = "terraformer.gardener.cloud/plan-state-serial"
+	// AnnotationPlanStateLineage is the annotation on plan Secrets, that records the lineage of the state, that the plan
+	// was created against. It is empty if the state was empty.
+	AnnotationPlanStateLineage = "terraformer.gardener.cloud/plan-state-lineage"
+	// AnnotationPlanConfigSHA256 is the annotation on plan Secrets, that records the checksum of the terraform config
+	// and variables, that the plan was created with.
+	AnnotationPlanConfigSHA256 = "terraformer.gardener.cloud/plan-config-sha256"
+
+	// ExitCodeStalePlan is the exit code of terraformer if the stored plan can't be applied, because the state or config
+	// changed since the plan was created.
+	ExitCodeStalePlan = 11
+
+	// exitCodePendingChanges is the exit code of `terraform plan -detailed-exitcode` if the plan contains changes.
+	exitCodePendingChanges = 2
+)
+
+// plan creates a plan file with `terraform plan` and stores it together with its JSON rendering in the plan Secret.
+func (t *Terraformer) plan(ctx context.Context) error {
+	log := t.stepLogger("plan")
+
+	if err := t.executeTerraform(ctx, Plan, "-out="+t.paths.PlanPath); err != nil {
+		return fmt.Errorf("error executing terraform %s: %w", Plan, err)
+	}
+
+	plan, err := os.ReadFile(t.paths.PlanPath)
+	if err != nil {
+		return fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	planJSON, err := t.showPlan(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing terraform %s: %w", Show, err)
+	}
+
+	stateSerial, stateLineage, err := t.stateRevision()
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace: t.config.Namespace,
+		Name:      t.config.PlanSecretName,
+		Labels:    map[string]string{LabelPlanOf: t.config.StateConfigMapName},
+		Annotations: map[string]string{
+			AnnotationPlanPendingChanges: strconv.FormatBool(t.pendingChanges),
+			AnnotationPlanStateSerial:    stateSerial,
+			AnnotationPlanStateLineage:   stateLineage,
+			AnnotationPlanConfigSHA256:   t.configSHA256,
+		},
+	}}
+	obj := &SecretStore{secret}
+	if err := t.planStore(obj).Store(tfPlanKey, bytes.NewReader(plan)); err != nil {
+		return err
+	}
+	if err := t.planStore(obj).Store(tfPlanJSONKey, bytes.NewReader(planJSON)); err != nil {
+		return err
+	}
+
+	planLog := log.WithValues("secret", client.ObjectKeyFromObject(secret), "pendingChanges", t.pendingChanges)
+	if err := storeObject(ctx, planLog, t.client, obj); err != nil {
+		return fmt.Errorf("failed to store plan: %w", err)
+	}
+
+	planLog.Info("successfully stored plan")
+	return nil
+}
+
+// applyPlan fetches the plan file stored in the plan Secret and applies it with `terraform apply`. If the state or
+// config changed since the plan was created, the plan is rejected with ExitCodeStalePlan.
+func (t *Terraformer) applyPlan(ctx context.Context) error {
+	log := t.stepLogger("applyPlan")
+
+	key := client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.PlanSecretName}
+	log = log.WithValues("secret", key)
+	log.V(1).Info("fetching plan")
+
+	obj := &SecretStore{&corev1.Secret{}}
+	if err := t.client.Get(ctx, key, obj.Object()); err != nil {
+		return fmt.Errorf("failed to fetch plan: %w", err)
+	}
+
+	stateSerial, stateLineage, err := t.stateRevision()
+	if err != nil {
+		return err
+	}
+	if err := t.checkPlan(obj.Object(), stateSerial, stateLineage, t.configSHA256); err != nil {
+		log.Info("rejecting stale plan", "reason", err.Error())
+		return utils.WithTerraformerExitCode{Code: ExitCodeStalePlan, Underlying: err}
+	}
+
+	plan, err := readValue(t.planStore(obj), tfPlanKey)
+	if err != nil {
+		return fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	log.V(1).Info("copying plan to file", "file", t.paths.PlanPath)
+	if err := os.WriteFile(t.paths.PlanPath, plan, 0600); err != nil {
+		return err
+	}
+
+	if err := t.executeTerraform(ctx, Apply, t.paths.PlanPath); err != nil {
+		return fmt.Errorf("error executing terraform %s: %w", Apply, err)
+	}
+	return nil
+}
+
+// checkPlan checks whether the given plan Secret was created for the state object against the given state serial and
+// lineage and config checksum.
+func (t *Terraformer) checkPlan(secret client.Object, stateSerial, stateLineage, configChecksum string) error {
+	if planOf := secret.GetLabels()[LabelPlanOf]; planOf != t.config.StateConfigMapName {
+		return fmt.Errorf("plan was created for state %q instead of %q", planOf, t.config.StateConfigMapName)
+	}
+
+	annotations := secret.GetAnnotations()
+	// a state with another lineage is unrelated to the planned one, even if it has the same serial
+	if lineage, ok := annotations[AnnotationPlanStateLineage]; !ok || lineage != stateLineage {
+		return fmt.Errorf("plan was created against state lineage %q, but the current state lineage is %q", lineage, stateLineage)
+	}
+	if serial, ok := annotations[AnnotationPlanStateSerial]; !ok || serial != stateSerial {
+		return fmt.Errorf("plan was created against state serial %q, but the current state serial is %q", serial, stateSerial)
+	}
+	if annotations[AnnotationPlanConfigSHA256] != configChecksum {
+		return errors.New("config changed since the plan was created")
+	}
+	return nil
+}
+
+// applyingPlan returns whether terraformer applies the stored plan instead of planning and applying in one go.
+func (t *Terraformer) applyingPlan() bool {
+	return t.command == Apply && len(t.config.PlanSecretName) > 0
+}
+
+// stateRevision returns the serial and the lineage of the state in the state file, which are empty if the state is
+// empty.
+func (t *Terraformer) stateRevision() (string, string, error) {
+	state, err := os.ReadFile(t.paths.StatePath)
+	if err != nil || len(state) == 0 {
+		return "", "", err
+	}
+
+	metadata, err := parseStateMetadata(state)
+	if err != nil {
+		return "", "", err
+	}
+	return strconv.FormatInt(metadata.Serial, 10), metadata.Lineage, nil
+}
+
+// configChecksum returns the hex-encoded sha256 checksum of the fetched terraform config and variables files.
+func (t *Terraformer) configChecksum() (string, error) {
+	hash := sha256.New()
+	addFile := func(name, file string) error {
+		content, err := os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		// include the name and length of each file, so that moving content between files changes the checksum
+		_, _ = fmt.Fprintf(hash, "%s %d\n", name, len(content))
+		_, _ = hash.Write(content)
+		return nil
+	}
+
+	for _, file := range t.configFiles {
+		if err := addFile(file, filepath.Join(t.paths.ConfigDir, file)); err != nil {
+			return "", err
+		}
+	}
+	if err := addFile(tfVarsKey, t.paths.VarsPath); err != nil {
+		return "", err
+	}
+	for _, file := range t.varFiles {
+		if err := addFile(filepath.Base(file), file); err != nil {
+			return "", err
+		}
+	}
+	for _, env := range t.varEnv {
+		name, value, _ := strings.Cut(env, "=")
+		_, _ = fmt.Fprintf(hash, "%s %d\n%s", name, len(value), value)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// showPlan returns the JSON rendering of the plan file.
+func (t *Terraformer) showPlan(ctx context.Context) ([]byte, error) {
+	return t.captureTerraform(ctx, t.stepLogger("showPlan"), Show, "-json", t.paths.PlanPath)
+}
+
+// planStore wraps the given plan Secret for encoding and encrypting the plan like the state, as the plan contains the
+// prior state and the variables.
+func (t *Terraformer) planStore(obj Store) Store {
+	return t.stateStore(obj)
+}
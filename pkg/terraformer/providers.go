@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultProviderRegistry is the hostname of provider sources, that don't specify a hostname.
+	defaultProviderRegistry = "registry.terraform.io"
+
+	// ProviderMigrationConfigMapKey is the key in the provider migration ConfigMap, that holds one `from=to` pair of
+	// provider sources per line.
+	ProviderMigrationConfigMapKey = "migrations"
+)
+
+// DefaultProviderMigrations maps the legacy sources of providers (recorded in states of terraform 0.12 or lower) to
+// their sources used with terraform 0.13 or higher.
+var DefaultProviderMigrations = map[string]string{
+	"registry.terraform.io/-/aws":         "registry.terraform.io/hashicorp/aws",
+	"registry.terraform.io/-/azurerm":     "registry.terraform.io/hashicorp/azurerm",
+	"registry.terraform.io/-/google":      "registry.terraform.io/hashicorp/google",
+	"registry.terraform.io/-/google-beta": "registry.terraform.io/hashicorp/google-beta",
+	"registry.terraform.io/-/openstack":   "registry.terraform.io/terraform-provider-openstack/openstack",
+	"registry.terraform.io/-/alicloud":    "registry.terraform.io/hashicorp/alicloud",
+	"registry.terraform.io/-/template":    "registry.terraform.io/hashicorp/template",
+	"registry.terraform.io/-/null":        "registry.terraform.io/hashicorp/null",
+}
+
+var (
+	// providerRegexp matches provider addresses in states of terraform 0.13 or higher, e.g.
+	// `provider["registry.terraform.io/hashicorp/aws"].east`.
+	providerRegexp = regexp.MustCompile(`provider\["([^"]+)"\]`)
+	// legacyProviderRegexp matches provider addresses in states of terraform 0.12 or lower, e.g. `provider.aws.east`.
+	legacyProviderRegexp = regexp.MustCompile(`(?:^|\.)provider\.([^.]+)`)
+)
+
+// ParseProviderMigration parses a migration of a provider source in form `from=to`, e.g.
+// `registry.terraform.io/-/aws=hashicorp/aws`, and returns the normalized sources.
+func ParseProviderMigration(pair string) (string, string, error) {
+	from, to, ok := strings.Cut(pair, "=")
+	if !ok {
+		return "", "", fmt.Errorf("provider migration must be of the form from=to: %q", pair)
+	}
+
+	from, err := normalizeProviderSource(strings.TrimSpace(from))
+	if err != nil {
+		return "", "", err
+	}
+	to, err = normalizeProviderSource(strings.TrimSpace(to))
+	if err != nil {
+		return "", "", err
+	}
+	return from, to, nil
+}
+
+// normalizeProviderSource returns the given provider source in form `hostname/namespace/type`, sources in form
+// `namespace/type` belong to the default registry.
+func normalizeProviderSource(source string) (string, error) {
+	parts := strings.Split(source, "/")
+	if len(parts) == 2 {
+		parts = append([]string{defaultProviderRegistry}, parts...)
+	}
+	if len(parts) != 3 || slices.Contains(parts, "") {
+		return "", fmt.Errorf("provider source must be of the form [hostname/]namespace/type: %q", source)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// providerMigrations returns DefaultProviderMigrations overwritten by the configured provider migrations and the
+// migrations in the provider migration ConfigMap.
+func (t *Terraformer) providerMigrations(ctx context.Context) (map[string]string, error) {
+	migrations := make(map[string]string, len(DefaultProviderMigrations)+len(t.config.ProviderMigrations))
+	for from, to := range DefaultProviderMigrations {
+		migrations[from] = to
+	}
+	for from, to := range t.config.ProviderMigrations {
+		from, to, err := ParseProviderMigration(from + "=" + to)
+		if err != nil {
+			return nil, err
+		}
+		migrations[from] = to
+	}
+
+	if len(t.config.ProviderMigrationConfigMapName) > 0 {
+		key := client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.ProviderMigrationConfigMapName}
+		configMap := &corev1.ConfigMap{}
+		if err := t.client.Get(ctx, key, configMap); err != nil {
+			return nil, fmt.Errorf("failed to fetch provider migrations: %w", err)
+		}
+
+		lines, err := parseLines(configMap.Data[ProviderMigrationConfigMapKey])
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			from, to, err := ParseProviderMigration(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid provider migration in ConfigMap %q: %w", key, err)
+			}
+			migrations[from] = to
+		}
+	}
+	return migrations, nil
+}
+
+// migrateProviders replaces the providers in the state, that have a provider migration, with their new sources by
+// executing `terraform state replace-provider`. Migrations are not chained, i.e. every provider is replaced once.
+func (t *Terraformer) migrateProviders(ctx context.Context) error {
+	log := t.stepLogger("migrateProviders")
+
+	migrations, err := t.providerMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	state, err := t.readState(ctx, t.config.StateConfigMapName)
+	if err != nil {
+		return err
+	}
+	sources, err := stateProviderSources(state)
+	if err != nil {
+		return err
+	}
+
+	for _, from := range sources {
+		to, ok := migrations[from]
+		if !ok || to == from {
+			continue
+		}
+
+		log.Info("replacing provider in state", "from", from, "to", to)
+		if err := t.executeTerraform(ctx, StateReplaceProvider, from, to); err != nil {
+			return fmt.Errorf("error executing terraform %s %s %s: %w", StateReplaceProvider, from, to, err)
+		}
+	}
+	return nil
+}
+
+// stateProviderSources returns the sorted sources of all providers, that manage resources in the given state.
+// Providers of states of terraform 0.12 or lower are returned with their legacy source, e.g.
+// `registry.terraform.io/-/aws`.
+func stateProviderSources(state []byte) ([]string, error) {
+	if len(state) == 0 {
+		return nil, nil
+	}
+
+	var terraformState struct {
+		Resources []struct {
+			Provider string `json:"provider"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(state, &terraformState); err != nil {
+		return nil, fmt.Errorf("could not unmarshal terraform state from JSON: %w", err)
+	}
+
+	var sources []string
+	for _, resource := range terraformState.Resources {
+		var source string
+		if match := providerRegexp.FindStringSubmatch(resource.Provider); match != nil {
+			source = match[1]
+		} else if match := legacyProviderRegexp.FindStringSubmatch(resource.Provider); match != nil {
+			source = defaultProviderRegistry + "/-/" + match[1]
+		} else {
+			continue
+		}
+
+		if !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	slices.Sort(sources)
+	return sources, nil
+}
@@ -5,11 +5,13 @@
 package terraformer
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -17,7 +19,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -29,6 +33,15 @@ const (
 	FinalStateUpdateTimeout = time.Hour
 )
 
+const (
+	// AnnotationStateSHA256 is the annotation on the state object holding the hex-encoded sha256 checksum of the plain
+	// state.
+	AnnotationStateSHA256 = "terraformer.gardener.cloud/state-sha256"
+	// AnnotationConfigSHA256 is the annotation on the state object holding the hex-encoded sha256 checksum of the
+	// terraform config and variables, that the run storing the state was executed with.
+	AnnotationConfigSHA256 = "terraformer.gardener.cloud/config-sha256"
+)
+
 const (
 	// FinalStateUpdateKey is a key, which will be added to the state-update queue to trigger the final state update.
 	// It indicates, that the state update should be retried on any error (i.e. the worker should add the key back
@@ -40,49 +53,240 @@ const (
 	ContinuousStateUpdateKey
 )
 
-// StoreState stores the state file in the configured state ConfigMap.
+// StoreState stores the state file in the configured state object. States exceeding the configured chunk size are
+// split across multiple chunk objects.
 // It uses a hard timeout of 2m and doesn't retry the update on any error.
 func (t *Terraformer) StoreState(ctx context.Context) error {
-	return storeConfigMap(ctx, t.log, t.client, t.config.Namespace, t.config.StateConfigMapName, t.paths.StateDir, tfStateKey)
-}
+	file, err := os.Open(t.paths.StatePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-func storeConfigMap(ctx context.Context, log logr.Logger, c client.Client, ns, name, dir string, dataKeys ...string) error {
-	return storeObject(ctx, log.WithValues("kind", "ConfigMap"), c, &ConfigMapStore{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}}, dir, dataKeys...)
+	return t.storeState(ctx, t.newStateObject(t.config.StateConfigMapName), file)
 }
 
-func storeObject(ctx context.Context, log logr.Logger, c client.Client, obj Store, dir string, dataKeys ...string) error {
-	key := client.ObjectKey{Namespace: obj.Object().GetNamespace(), Name: obj.Object().GetName()}
-	log = log.WithValues("object", key)
+// storeState stores the given state in the given (empty) state object and splits it into chunks if necessary.
+// If the revision of the stored state is known, i.e. the state was fetched before, states overwriting a newer or
+// unrelated state are rejected, concurrent writes are detected by optimistic locking and updates are skipped if the
+// state didn't change.
+func (t *Terraformer) storeState(ctx context.Context, obj Store, data io.Reader) error {
+	log := t.log.WithValues("kind", t.stateKind(), "object", client.ObjectKeyFromObject(obj.Object()))
+
+	t.stateMutex.Lock()
+	defer t.stateMutex.Unlock()
+
+	// never overwrite the state of another terraformer, that took over the lease
+	if err := t.checkLease(); err != nil {
+		return err
+	}
 
 	// rather timeout after 2m (and retry) instead of hanging in a non-progressing connection
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithTimeout(ctx, stateUpdateTimeout)
 	defer cancel()
 
-	for _, dataKey := range dataKeys {
-		if err := func() error {
-			file, err := os.Open(filepath.Clean(filepath.Join(dir, dataKey)))
-			if err != nil {
+	state, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	checksum := stateChecksum(state)
+
+	if t.storedState == nil || obj.Object().GetName() != t.config.StateConfigMapName {
+		return t.storeTrackedStateObject(ctx, log, obj, state, checksum)
+	}
+
+	for i := 0; ; i++ {
+		var err error
+		if t.storedState.sha256 == checksum {
+			if len(t.configSHA256) == 0 || t.storedState.configSHA256 == t.configSHA256 {
+				t.stateUpdatesSkipped++
+				log.Info("state is unchanged, skipping update", "sha256", checksum, "skippedUpdates", t.stateUpdatesSkipped)
+				return nil
+			}
+
+			// a config change, that doesn't change any resources, leaves the state unchanged
+			if err = t.storeConfigChecksum(ctx, log); err == nil {
+				return nil
+			}
+		} else {
+			var metadata *stateMetadata
+			if metadata, err = t.checkStateRevision(log, state); err != nil {
 				return err
 			}
-			defer file.Close()
-			log.V(1).Info("copying file content into object", "dataKey", dataKey, "file", file.Name())
 
-			return obj.Store(dataKey, file)
-		}(); err != nil {
+			obj.Object().SetResourceVersion(t.storedState.resourceVersion)
+			if err = t.storeTrackedStateObject(ctx, log, obj, state, checksum); err == nil {
+				t.stateUpdatesPerformed++
+				log.Info("successfully updated state", "sha256", checksum, "performedUpdates", t.stateUpdatesPerformed)
+				t.storedState = &storedState{
+					metadata:        metadata,
+					resourceVersion: obj.Object().GetResourceVersion(),
+					sha256:          checksum,
+					configSHA256:    obj.Object().GetAnnotations()[AnnotationConfigSHA256],
+				}
+				return nil
+			}
+		}
+		if !apierrors.IsConflict(err) || i >= maxPatchRetries {
 			return err
 		}
+
+		// the state object was modified in the meantime, check the stored state again before retrying
+		log.Info("state object was modified concurrently, checking stored state again")
+		if err := t.refreshStoredState(ctx); err != nil {
+			return err
+		}
+		obj = t.newStateObject(t.config.StateConfigMapName)
+	}
+}
+
+// storeConfigChecksum records the checksum of the config on the unchanged state object. It has to be called with the
+// stateMutex held.
+func (t *Terraformer) storeConfigChecksum(ctx context.Context, log logr.Logger) error {
+	obj := t.newStateObject(t.config.StateConfigMapName).Object()
+	obj.SetResourceVersion(t.storedState.resourceVersion)
+
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	obj.SetAnnotations(map[string]string{AnnotationConfigSHA256: t.configSHA256})
+	if err := t.client.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+
+	log.Info("state is unchanged, updated config checksum", "configSHA256", t.configSHA256)
+	t.storedState.resourceVersion = obj.GetResourceVersion()
+	t.storedState.configSHA256 = t.configSHA256
+	return nil
+}
+
+// storeTrackedStateObject stores the given state in the given object and records whether the state object is split into
+// chunks. Other objects (e.g. state revisions) are never overwritten, hence they aren't chunked before.
+func (t *Terraformer) storeTrackedStateObject(ctx context.Context, log logr.Logger, obj Store, state []byte, checksum string) error {
+	if obj.Object().GetName() != t.config.StateConfigMapName {
+		_, err := t.storeStateObject(ctx, log, obj, state, checksum, false)
+		return err
+	}
+
+	chunked, err := t.storeStateObject(ctx, log, obj, state, checksum, t.stateChunked)
+	if err != nil {
+		return err
+	}
+	t.stateChunked = chunked
+	return nil
+}
+
+// storeStateObject stores the given state in the given object and splits it into chunks if necessary. If the object
+// was chunked before and the state fits into a single object again, the manifest and the chunks of the previous state
+// are removed. It returns whether the state was split into chunks.
+func (t *Terraformer) storeStateObject(ctx context.Context, log logr.Logger, obj Store, state []byte, checksum string, wasChunked bool) (bool, error) {
+	log.V(1).Info("copying state into object", "dataKey", tfStateKey)
+	if err := t.stateStore(obj).Store(tfStateKey, bytes.NewReader(state)); err != nil {
+		return false, err
+	}
+	// the checksum of the plain state allows detecting changes without decoding the state
+	annotations := obj.Object().GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationStateSHA256] = checksum
+	// the config checksum is only known if the config was fetched, and it doesn't apply to older states in the history
+	if len(t.configSHA256) > 0 && obj.Object().GetName() == t.config.StateConfigMapName {
+		annotations[AnnotationConfigSHA256] = t.configSHA256
+	}
+	obj.Object().SetAnnotations(annotations)
+
+	// the (encoded) value in the object decides whether the state has to be chunked
+	value, err := readValue(obj, tfStateKey)
+	if err != nil {
+		return false, err
+	}
+	if len(value) > t.stateChunkSize() {
+		return true, t.storeChunkedState(ctx, log, obj, value)
+	}
+
+	if !wasChunked {
+		return false, storeObject(ctx, log, t.client, obj)
+	}
+
+	// the state fits into a single object again, remove the manifest and the chunks of the previous state
+	if err := storeObject(ctx, log, t.client, obj, tfStateManifestKey); err != nil {
+		return false, err
+	}
+	return false, t.cleanupStateChunks(ctx, log, obj.Object().GetName())
+}
+
+// RekeyState re-encrypts the stored state and all revisions in the state history with the active encryption key, i.e.
+// the states are decrypted with any of the configured keys and stored again. Afterwards, previous keys can be retired.
+// The lease is held while re-encrypting.
+func (t *Terraformer) RekeyState(ctx context.Context) error {
+	return t.withLease(ctx, t.rekeyState)
+}
+
+func (t *Terraformer) rekeyState(ctx context.Context) error {
+	log := t.stepLogger("RekeyState")
+
+	if t.config.StateEncryptionKeys == nil || t.config.StateEncryptionKeys.ActiveKeyID == "" {
+		return fmt.Errorf("no active encryption key configured")
+	}
+
+	revisions, err := t.listStateHistory(ctx)
+	if err != nil {
+		return err
+	}
+	names := []string{t.config.StateConfigMapName}
+	for _, revision := range revisions {
+		names = append(names, revision.GetName())
+	}
+
+	for _, name := range names {
+		log.Info("re-encrypting state", "object", name, "keyID", t.config.StateEncryptionKeys.ActiveKeyID)
+		if err := t.rekeyStateObject(ctx, log, name); err != nil {
+			return fmt.Errorf("failed to re-encrypt state in %q: %w", name, err)
+		}
 	}
 
+	log.Info("successfully re-encrypted state", "revisions", len(revisions))
+	return nil
+}
+
+// rekeyStateObject stores the state of the given object (the state object or a state revision) again, which encrypts
+// it with the active key. If the state was split into chunks, the chunks encrypted with the previous key are removed.
+func (t *Terraformer) rekeyStateObject(ctx context.Context, log logr.Logger, name string) error {
+	state, _, chunked, err := t.readStateObject(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	t.stateMutex.Lock()
+	defer t.stateMutex.Unlock()
+
+	if err := t.checkLease(); err != nil {
+		return err
+	}
+
+	_, err = t.storeStateObject(ctx, log.WithValues("object", name), t.newStateObject(name), state, stateChecksum(state), chunked)
+	return err
+}
+
+// storeObject stores the given object by patching it and removing the given data keys. If the object doesn't exist
+// yet, it is created instead.
+func storeObject(ctx context.Context, log logr.Logger, c client.Client, obj Store, removedKeys ...string) error {
 	log.V(1).Info("storing object")
 
+	patch, err := mergePatch(obj.Object(), removedKeys...)
+	if err != nil {
+		return err
+	}
+
 	if err := func() error {
 		// try patch first and fallback to create if the object doesn't exist
 		// to avoid always sending two requests in the "normal" case
 		// this will reduce API calls for storing the state by roughly 1/2
-		err := c.Patch(ctx, obj.Object(), client.Merge)
+		err := c.Patch(ctx, obj.Object(), patch)
 		if err != nil {
 			if apierrors.IsNotFound(err) {
+				// objects can't be created with a resourceVersion
+				obj.Object().SetResourceVersion("")
 				return c.Create(ctx, obj.Object())
 			}
 		}
@@ -95,6 +299,100 @@ func storeObject(ctx context.Context, log logr.Logger, c client.Client, obj Stor
 	return nil
 }
 
+// mergePatch returns a merge patch for the given object, which additionally removes the given data keys. A plain
+// client.Merge patch can't remove any fields, as the object can't express null values. Hence, the patch also removes
+// stale values, which moved between a ConfigMap's data and binaryData, and the encoding and encryption annotations if
+// they aren't set.
+func mergePatch(obj client.Object, removedKeys ...string) (client.Patch, error) {
+	objData, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(objData, &patch); err != nil {
+		return nil, err
+	}
+
+	for _, key := range removedKeys {
+		setNull(patch, "data", key)
+	}
+	if configMap, ok := obj.(*corev1.ConfigMap); ok {
+		for _, key := range removedKeys {
+			setNull(patch, "binaryData", key)
+		}
+		for key := range configMap.Data {
+			setNull(patch, "binaryData", key)
+		}
+		for key := range configMap.BinaryData {
+			setNull(patch, "data", key)
+		}
+	}
+	for _, annotation := range []string{AnnotationEncoding, AnnotationEncryptionKeyID, AnnotationEncryptionDataKey} {
+		if _, ok := obj.GetAnnotations()[annotation]; !ok {
+			setNull(patch, "metadata", "annotations", annotation)
+		}
+	}
+
+	patchData, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	return client.RawPatch(types.MergePatchType, patchData), nil
+}
+
+// setNull sets the field with the given path in the given patch to null, i.e. the field is removed by the patch.
+func setNull(patch map[string]interface{}, path ...string) {
+	for _, field := range path[:len(path)-1] {
+		next, ok := patch[field].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			patch[field] = next
+		}
+		patch = next
+	}
+	patch[path[len(path)-1]] = nil
+}
+
+// newStateObject returns an empty Store for the state object with the given name.
+func (t *Terraformer) newStateObject(name string) Store {
+	objectMeta := metav1.ObjectMeta{Namespace: t.config.Namespace, Name: name}
+	if t.stateKind() == StateKindSecret {
+		return &SecretStore{&corev1.Secret{ObjectMeta: objectMeta}}
+	}
+	return &ConfigMapStore{&corev1.ConfigMap{ObjectMeta: objectMeta}}
+}
+
+// stateStore wraps the given state object for encoding and encrypting the state according to the configuration.
+// The state is compressed before it is encrypted, as encrypted data can't be compressed.
+func (t *Terraformer) stateStore(obj Store) Store {
+	encoding := ""
+	if t.config.CompressState {
+		encoding = EncodingGzip
+	}
+
+	return &EncodingStore{
+		Underlying: &EncryptingStore{Underlying: obj, Keys: t.config.StateEncryptionKeys},
+		Encoding:   encoding,
+	}
+}
+
+// newStateObjectList returns an empty list for listing state objects.
+func (t *Terraformer) newStateObjectList() client.ObjectList {
+	if t.stateKind() == StateKindSecret {
+		return &corev1.SecretList{}
+	}
+	return &corev1.ConfigMapList{}
+}
+
+// stateKind returns the kind of the state object.
+func (t *Terraformer) stateKind() StateKind {
+	if t.config.StateKind == "" {
+		return StateKindConfigMap
+	}
+	return t.config.StateKind
+}
+
 // StartStateUpdateWorker starts a worker goroutine, that will read from the state-update queue and call StoreState for
 // every item. It returns a func that should be executed as part of the shutdown procedure, which shuts down the
 // workqueue and the worker and then waits until the worker has finished the last work item.
@@ -151,6 +449,15 @@ func (t *Terraformer) processNextStateUpdate() bool {
 	// StoreState itself configures a timeout for the API calls
 	if err := t.StoreState(context.Background()); err != nil {
 		log.Error(err, "error storing state")
+		if isFinalStateUpdate && (errors.Is(err, errStaleState) || errors.Is(err, errLeaseLost)) {
+			// retrying doesn't help, the stored state is newer than the state file or the lease was lost
+			t.StateUpdateQueue.Forget(key)
+			select {
+			case t.finalStateUpdateFailed <- err:
+			default: // don't block the worker, if the receiver of the channel is not ready
+			}
+			return true
+		}
 		if isFinalStateUpdate {
 			log.V(1).Info("adding item back to queue with backoff after error")
 			t.StateUpdateQueue.AddRateLimited(key)
@@ -160,6 +467,11 @@ func (t *Terraformer) processNextStateUpdate() bool {
 	t.StateUpdateQueue.Forget(key)
 
 	if isFinalStateUpdate {
+		// a failure to record the state history must not block terraformer from exiting, the state itself is stored
+		if err := t.storeStateHistory(context.Background(), commandLabel(t.command)); err != nil {
+			log.Error(err, "error storing state history")
+		}
+
 		// signal that final state update has succeeded and we can safely exit
 		select {
 		case t.FinalStateUpdateSucceeded <- struct{}{}:
@@ -171,10 +483,16 @@ func (t *Terraformer) processNextStateUpdate() bool {
 }
 
 // StartFileWatcher watches the state file for changes and stores the file contents in the state ConfigMap as soon as
-// the file gets updated.
+// the file gets updated. The state directory is watched instead of the file itself, so that changes are also detected
+// if the file is replaced, e.g. by renaming another file. If a poll interval is configured, the state file is polled
+// for changes of its modification time and size instead, e.g. for filesystems without inotify support.
 // It returns a func that should be executed as part of the shutdown procedure, which stops the file watcher and
 // waits for the event handler goroutine to finish.
 func (t *Terraformer) StartFileWatcher() (func(), error) {
+	if t.config.StateFilePollInterval > 0 {
+		return t.startFilePoller()
+	}
+
 	log := t.log.WithName("file-watcher")
 
 	watcher, err := fsnotify.NewWatcher()
@@ -188,6 +506,9 @@ func (t *Terraformer) StartFileWatcher() (func(), error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	wg.StartWithContext(ctx, func(ctx context.Context) {
+		trigger := &stateUpdateTrigger{t: t, log: log}
+		defer trigger.stop()
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -198,14 +519,19 @@ func (t *Terraformer) StartFileWatcher() (func(), error) {
 				if !ok {
 					return
 				}
+				if event.Name != t.paths.StatePath {
+					continue
+				}
 
 				fileLog := log.WithValues("file", event.Name)
 				fileLog.V(1).Info("received event for file", "op", event.Op.String())
 
-				if event.Name == t.paths.StatePath && event.Op&fsnotify.Write == fsnotify.Write {
-					fileLog.V(1).Info("triggering state update")
-					t.StateUpdateQueue.Add(ContinuousStateUpdateKey)
+				// files renamed to the state file are reported as created
+				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+					trigger.stateFileChanged(fileLog)
 				}
+			case <-trigger.C():
+				trigger.triggerStateUpdate()
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
@@ -215,18 +541,152 @@ func (t *Terraformer) StartFileWatcher() (func(), error) {
 		}
 	})
 
-	log.Info("starting file watcher for state file", "file", t.paths.StatePath)
-	if err := watcher.Add(t.paths.StatePath); err != nil {
+	log.Info("starting file watcher for state file", "file", t.paths.StatePath, "dir", t.paths.StateDir)
+	if err := watcher.Add(t.paths.StateDir); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return func() {
 		cancel()
+		wg.Wait()
+	}, nil
+}
+
+// fileStat holds the properties of the state file, that are compared for detecting changes when polling.
+type fileStat struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// startFilePoller polls the state file for changes of its modification time and size and stores the file contents in
+// the state ConfigMap as soon as the file gets updated.
+func (t *Terraformer) startFilePoller() (func(), error) {
+	log := t.log.WithName("file-poller")
+
+	stat := func() (fileStat, error) {
+		info, err := os.Stat(t.paths.StatePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fileStat{}, nil
+			}
+			return fileStat{}, err
+		}
+		return fileStat{exists: true, modTime: info.ModTime(), size: info.Size()}, nil
+	}
+
+	last, err := stat()
+	if err != nil {
 		return nil, err
 	}
 
+	var wg wait.Group
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wg.StartWithContext(ctx, func(ctx context.Context) {
+		trigger := &stateUpdateTrigger{t: t, log: log}
+		defer trigger.stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.V(1).Info("stopping file poller")
+				return
+			case <-t.clock.After(t.config.StateFilePollInterval):
+				current, err := stat()
+				if err != nil {
+					log.Error(err, "error while polling state file")
+					continue
+				}
+
+				// deleting the state file is not a change, same as for the file watcher
+				if current.exists && current != last {
+					trigger.stateFileChanged(log.WithValues("file", t.paths.StatePath))
+				}
+				last = current
+			case <-trigger.C():
+				trigger.triggerStateUpdate()
+			}
+		}
+	})
+
+	log.Info("starting file poller for state file", "file", t.paths.StatePath, "interval", t.config.StateFilePollInterval.String())
+
 	return func() {
 		cancel()
 		wg.Wait()
 	}, nil
 }
 
+// stateUpdateTrigger triggers continuous state updates for changes of the state file. If configured, the state updates
+// are debounced, i.e. a state update is only triggered once the state file hasn't changed for the debounce window.
+// It is not safe for concurrent use.
+type stateUpdateTrigger struct {
+	t   *Terraformer
+	log logr.Logger
+
+	// debounce fires once the pending state update should be triggered, it is nil if no update is pending
+	debounce clock.Timer
+	// firstPending is the time of the oldest change, that is covered by the pending state update
+	firstPending time.Time
+}
+
+// stateFileChanged triggers a state update or delays it if state updates are debounced.
+func (s *stateUpdateTrigger) stateFileChanged(log logr.Logger) {
+	if s.t.config.StateUpdateDebounce <= 0 {
+		log.V(1).Info("triggering state update")
+		s.t.StateUpdateQueue.Add(ContinuousStateUpdateKey)
+		return
+	}
+
+	now := s.t.clock.Now()
+	if s.debounce == nil {
+		s.firstPending = now
+	} else {
+		s.debounce.Stop()
+	}
+	delay := s.t.stateUpdateDelay(now, s.firstPending)
+	log.V(1).Info("delaying state update", "delay", delay.String())
+	s.debounce = s.t.clock.NewTimer(delay)
+}
+
+// C returns a channel, that receives a value once the pending state update should be triggered. If no update is
+// pending, the returned channel is nil, i.e. it blocks forever.
+func (s *stateUpdateTrigger) C() <-chan time.Time {
+	if s.debounce == nil {
+		return nil
+	}
+	return s.debounce.C()
+}
+
+// triggerStateUpdate triggers the pending state update.
+func (s *stateUpdateTrigger) triggerStateUpdate() {
+	s.debounce = nil
+	s.log.V(1).Info("triggering state update")
+	s.t.StateUpdateQueue.Add(ContinuousStateUpdateKey)
+}
+
+// stop stops the pending state update, it is covered by the final state update.
+func (s *stateUpdateTrigger) stop() {
+	if s.debounce != nil {
+		s.debounce.Stop()
+	}
+}
+
+// stateUpdateDelay returns the delay of a continuous state update, which is triggered once the state file hasn't changed
+// for the debounce window. Updates are not delayed longer than the max delay after the first change of the state file.
+func (t *Terraformer) stateUpdateDelay(now, firstPending time.Time) time.Duration {
+	delay := t.config.StateUpdateDebounce
+	if t.config.StateUpdateMaxDelay <= 0 {
+		return delay
+	}
+	if remaining := firstPending.Add(t.config.StateUpdateMaxDelay).Sub(now); remaining < delay {
+		delay = max(remaining, 0)
+	}
+	return delay
+}
+
 // TriggerAndWaitForFinalStateUpdate triggers the final state update and waits until it has succeeded or timed out.
 func (t *Terraformer) TriggerAndWaitForFinalStateUpdate() error {
 	log := t.stepLogger("finalStateUpdate")
@@ -243,10 +703,17 @@ func (t *Terraformer) TriggerAndWaitForFinalStateUpdate() error {
 			log.Error(err2, "failed copying state contents to stdout, now things are messed up and you probably need to cleanup manually :(")
 		}
 		return err
+	case err := <-t.finalStateUpdateFailed:
+		log.Error(err, "error updating state")
+		log.Info("logging contents of state file to stdout as last resort")
+		if err2 := t.LogStateContentsToStdout(); err2 != nil {
+			log.Error(err2, "failed copying state contents to stdout")
+		}
+		return err
 	case <-t.FinalStateUpdateSucceeded:
 	}
 
-	log.Info("successfully stored terraform state")
+	log.Info("successfully stored terraform state", "performedUpdates", t.stateUpdatesPerformed, "skippedUpdates", t.stateUpdatesSkipped)
 	return nil
 }
 
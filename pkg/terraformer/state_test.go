@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -109,6 +110,53 @@ var _ = Describe("Terraformer State", func() {
 			testObjs.Refresh()
 			Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateContents))
 		})
+		It("should store compressed state and fetch it again", func() {
+			var err error
+			tf, err = terraformer.NewTerraformer(
+				&terraformer.Config{
+					Namespace:                  testObjs.Namespace,
+					ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+					StateConfigMapName:         testObjs.StateConfigMap.Name,
+					VariablesSecretName:        testObjs.VariablesSecret.Name,
+					RESTConfig:                 restConfig,
+					CompressState:              true,
+				},
+				zap.New(zap.UseDevMode(true), zap.WriteTo(io.MultiWriter(GinkgoWriter, logBuffer))),
+				paths,
+				fakeClock,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			stateContents := "state from new run"
+			Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncoding, terraformer.EncodingGzip))
+			Expect(testObjs.StateConfigMap.Data).NotTo(HaveKey(testutils.StateKey))
+			Expect(testObjs.StateConfigMap.BinaryData).To(HaveKey(testutils.StateKey))
+
+			Expect(os.WriteFile(paths.StatePath, nil, 0644)).To(Succeed())
+			Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+			Expect(paths.StatePath).To(testutils.BeFileWithContents(Equal(stateContents)))
+		})
+		It("should store uncompressed state over compressed state", func() {
+			testObjs.StateConfigMap.Annotations = map[string]string{terraformer.AnnotationEncoding: terraformer.EncodingGzip}
+			testObjs.StateConfigMap.BinaryData = map[string][]byte{testutils.StateKey: {0x1f, 0x8b}}
+			delete(testObjs.StateConfigMap.Data, testutils.StateKey)
+			Expect(testClient.Update(ctx, testObjs.StateConfigMap)).To(Succeed())
+
+			stateContents := "state from new run"
+			Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+			Expect(tf.StoreState(ctx)).To(Succeed())
+
+			testObjs.Refresh()
+			Expect(testObjs.StateConfigMap.Annotations).NotTo(HaveKey(terraformer.AnnotationEncoding))
+			Expect(testObjs.StateConfigMap.BinaryData).NotTo(HaveKey(testutils.StateKey))
+			Expect(testObjs.StateConfigMap.Data).To(HaveKeyWithValue(testutils.StateKey, stateContents))
+		})
 		It("should fail if state file is not present", func() {
 			Expect(os.Remove(paths.StatePath)).To(Or(Succeed(), MatchError(ContainSubstring("no such file"))))
 
@@ -298,6 +346,162 @@ var _ = Describe("Terraformer State", func() {
 				return testObjs.StateConfigMap.Data
 			}, 1, 0.1).Should(HaveKeyWithValue(testutils.StateKey, stateContents))
 		})
+		It("should update state ConfigMap if file is replaced", func() {
+			stateContents := "state, generation 1"
+			tmpFile := filepath.Join(paths.StateDir, "terraform.tfstate.tmp")
+			Expect(os.WriteFile(tmpFile, []byte(stateContents), 0644)).To(Succeed())
+			Expect(os.Rename(tmpFile, paths.StatePath)).To(Succeed())
+
+			Eventually(func() map[string]string {
+				testObjs.Refresh()
+				return testObjs.StateConfigMap.Data
+			}, 1, 0.1).Should(HaveKeyWithValue(testutils.StateKey, stateContents))
+
+			By("update state file after it was replaced")
+			stateContents = "state, generation 2"
+			Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+			Eventually(func() map[string]string {
+				testObjs.Refresh()
+				return testObjs.StateConfigMap.Data
+			}, 1, 0.1).Should(HaveKeyWithValue(testutils.StateKey, stateContents))
+		})
+	})
+
+	Describe("#StartFileWatcher with polling", func() {
+		var (
+			shutdownWorker, shutdownFileWatcher func()
+		)
+
+		BeforeEach(func() {
+			var err error
+			tf, err = terraformer.NewTerraformer(
+				&terraformer.Config{
+					Namespace:                  testObjs.Namespace,
+					ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+					StateConfigMapName:         testObjs.StateConfigMap.Name,
+					VariablesSecretName:        testObjs.VariablesSecret.Name,
+					RESTConfig:                 restConfig,
+					StateFilePollInterval:      time.Second,
+				},
+				zap.New(zap.UseDevMode(true), zap.WriteTo(io.MultiWriter(GinkgoWriter, logBuffer))),
+				paths,
+				fakeClock,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+
+			shutdownWorker = tf.StartStateUpdateWorker()
+			shutdownFileWatcher, err = tf.StartFileWatcher()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			shutdownFileWatcher()
+			shutdownWorker()
+		})
+
+		It("should not update state ConfigMap if file is not changed", func() {
+			stateBefore := testObjs.StateConfigMap.DeepCopy()
+
+			Consistently(func() runtime.Object {
+				fakeClock.Step(time.Second)
+				testObjs.Refresh()
+				return testObjs.StateConfigMap
+			}, 1, 0.1).Should(DeepEqual(stateBefore))
+		})
+		It("should update state ConfigMap if file is changed", func() {
+			stateContents := "state, generation 1"
+			Expect(os.WriteFile(paths.StatePath, []byte(stateContents), 0644)).To(Succeed())
+
+			Eventually(func() map[string]string {
+				fakeClock.Step(time.Second)
+				testObjs.Refresh()
+				return testObjs.StateConfigMap.Data
+			}, 1, 0.1).Should(HaveKeyWithValue(testutils.StateKey, stateContents))
+		})
+	})
+
+	Describe("#StartFileWatcher with debounced state updates", func() {
+		var (
+			shutdownWorker, shutdownFileWatcher func()
+
+			startFileWatcher func(debounce, maxDelay time.Duration)
+			writeState       func(state string)
+		)
+
+		BeforeEach(func() {
+			startFileWatcher = func(debounce, maxDelay time.Duration) {
+				var err error
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						RESTConfig:                 restConfig,
+						StateUpdateDebounce:        debounce,
+						StateUpdateMaxDelay:        maxDelay,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(io.MultiWriter(GinkgoWriter, logBuffer))),
+					paths,
+					fakeClock,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tf.FetchConfigAndState(ctx)).To(Succeed())
+
+				shutdownWorker = tf.StartStateUpdateWorker()
+				shutdownFileWatcher, err = tf.StartFileWatcher()
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			writeState = func(state string) {
+				Expect(os.WriteFile(paths.StatePath, []byte(state), 0644)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("delaying state update"))
+				// a single write might cause multiple events, give the file watcher some time to handle all of them
+				// before stepping the clock
+				time.Sleep(100 * time.Millisecond)
+			}
+		})
+
+		AfterEach(func() {
+			shutdownFileWatcher()
+			shutdownWorker()
+		})
+
+		It("should collapse state file writes within the debounce window", func() {
+			startFileWatcher(10*time.Second, 0)
+			stateBefore := testObjs.StateConfigMap.DeepCopy()
+
+			writeState("state, generation 1")
+			fakeClock.Step(5 * time.Second)
+			writeState("state, generation 2")
+			fakeClock.Step(5 * time.Second)
+
+			Consistently(func() runtime.Object {
+				testObjs.Refresh()
+				return testObjs.StateConfigMap
+			}, 0.5, 0.1).Should(DeepEqual(stateBefore))
+
+			fakeClock.Step(5 * time.Second)
+			Eventually(func() map[string]string {
+				testObjs.Refresh()
+				return testObjs.StateConfigMap.Data
+			}, 1, 0.1).Should(HaveKeyWithValue(testutils.StateKey, "state, generation 2"))
+		})
+		It("should not delay state updates longer than the max delay", func() {
+			startFileWatcher(10*time.Second, 15*time.Second)
+
+			writeState("state, generation 1")
+			fakeClock.Step(8 * time.Second)
+			writeState("state, generation 2")
+			fakeClock.Step(7 * time.Second)
+
+			Eventually(func() map[string]string {
+				testObjs.Refresh()
+				return testObjs.StateConfigMap.Data
+			}, 1, 0.1).Should(HaveKeyWithValue(testutils.StateKey, "state, generation 2"))
+		})
 	})
 
 	Describe("#TriggerAndWaitForFinalStateUpdate", func() {
@@ -6,9 +6,11 @@ package terraformer
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"strings"
+	"unicode/utf8"
 
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -45,29 +47,42 @@ func (c *ConfigMapStore) Object() client.Object {
 	return c.ConfigMap
 }
 
-// Read returns a reader for reading the value of the given key in the ConfigMap.
+// Read returns a reader for reading the value of the given key in the ConfigMap. Values, which are not found in the
+// ConfigMap's data, are read from its binaryData.
 func (c *ConfigMapStore) Read(key string) (io.Reader, error) {
-	data, ok := c.Data[key]
-	if !ok {
-		return nil, KeyNotFoundError(key)
+	if data, ok := c.Data[key]; ok {
+		return strings.NewReader(data), nil
+	}
+	if data, ok := c.BinaryData[key]; ok {
+		return bytes.NewReader(data), nil
 	}
 
-	return strings.NewReader(data), nil
+	return nil, KeyNotFoundError(key)
 }
 
-// Store reads from the given reader and stores the contents under the given key in the ConfigMap.
+// Store reads from the given reader and stores the contents under the given key in the ConfigMap. Contents, which are
+// not valid UTF-8 (e.g. compressed values), are stored in the ConfigMap's binaryData.
 func (c *ConfigMapStore) Store(key string, data io.Reader) error {
-	if c.Data == nil {
-		c.Data = make(map[string]string, 1)
-	}
-
 	buf := &bytes.Buffer{}
 	_, err := io.Copy(buf, data)
 	if err != nil {
 		return err
 	}
 
+	if !utf8.Valid(buf.Bytes()) {
+		if c.BinaryData == nil {
+			c.BinaryData = make(map[string][]byte, 1)
+		}
+		c.BinaryData[key] = buf.Bytes()
+		delete(c.Data, key)
+		return nil
+	}
+
+	if c.Data == nil {
+		c.Data = make(map[string]string, 1)
+	}
 	c.Data[key] = buf.String()
+	delete(c.BinaryData, key)
 	return nil
 }
 
@@ -108,3 +123,86 @@ func (s *SecretStore) Store(key string, data io.Reader) error {
 	s.Data[key] = buf.Bytes()
 	return nil
 }
+
+const (
+	// AnnotationEncoding is the annotation recording the encoding of the values stored by an EncodingStore.
+	AnnotationEncoding = "terraformer.gardener.cloud/encoding"
+	// EncodingGzip is the encoding for values compressed with gzip.
+	EncodingGzip = "gzip"
+)
+
+var _ Store = &EncodingStore{}
+
+// EncodingStore wraps a Store and encodes all values with the given Encoding before storing them. The encoding is
+// recorded in the AnnotationEncoding annotation of the object. Values are always decoded according to this annotation,
+// so that objects stored with a different encoding (or without any encoding) can still be read.
+type EncodingStore struct {
+	// Underlying is the Store to store the encoded values in.
+	Underlying Store
+	// Encoding is the encoding to apply when storing values. If empty, values are stored as is.
+	Encoding string
+}
+
+// Object returns the object of the underlying Store.
+func (e *EncodingStore) Object() client.Object {
+	return e.Underlying.Object()
+}
+
+// Read returns a reader for reading the decoded value of the given key in the underlying Store.
+func (e *EncodingStore) Read(key string) (io.Reader, error) {
+	reader, err := e.Underlying.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch encoding := e.Object().GetAnnotations()[AnnotationEncoding]; encoding {
+	case "":
+		return reader, nil
+	case EncodingGzip:
+		data, err := io.ReadAll(reader)
+		if err != nil || len(data) == 0 {
+			return bytes.NewReader(data), err
+		}
+		gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress value of key %q: %w", key, err)
+		}
+		return gzipReader, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// Store encodes the contents of the given reader and stores them under the given key in the underlying Store.
+func (e *EncodingStore) Store(key string, data io.Reader) error {
+	switch e.Encoding {
+	case "":
+		annotations := e.Object().GetAnnotations()
+		delete(annotations, AnnotationEncoding)
+		e.Object().SetAnnotations(annotations)
+		return e.Underlying.Store(key, data)
+	case EncodingGzip:
+		buf := &bytes.Buffer{}
+		gzipWriter := gzip.NewWriter(buf)
+		if _, err := io.Copy(gzipWriter, data); err != nil {
+			return err
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return err
+		}
+		if err := e.Underlying.Store(key, buf); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported encoding %q", e.Encoding)
+	}
+
+	// the underlying Store might have changed the annotations as well
+	annotations := e.Object().GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[AnnotationEncoding] = e.Encoding
+	e.Object().SetAnnotations(annotations)
+	return nil
+}
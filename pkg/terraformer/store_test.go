@@ -6,6 +6,7 @@ package terraformer_test
 
 import (
 	"bytes"
+	"compress/gzip"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -56,6 +57,22 @@ var _ = Describe("ConfigMapStore", func() {
 			Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
 			Expect(cm.Data).To(HaveKeyWithValue("foo", "bar"))
 		})
+		It("should store binary value in binaryData", func() {
+			cm.Data["foo"] = "bar"
+			Expect(s.Store("foo", bytes.NewBuffer([]byte{0xff, 0xfe}))).To(Succeed())
+			Expect(cm.Data).NotTo(HaveKey("foo"))
+			Expect(cm.BinaryData).To(HaveKeyWithValue("foo", []byte{0xff, 0xfe}))
+
+			reader, err := s.Read("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^\xff\xfe$"))
+		})
+		It("should move value from binaryData to data", func() {
+			cm.BinaryData = map[string][]byte{"foo": {0xff, 0xfe}}
+			Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+			Expect(cm.BinaryData).NotTo(HaveKey("foo"))
+			Expect(cm.Data).To(HaveKeyWithValue("foo", "bar"))
+		})
 	})
 })
 
@@ -102,3 +119,76 @@ var _ = Describe("SecretStore", func() {
 		})
 	})
 })
+
+var _ = Describe("EncodingStore", func() {
+	var (
+		s  *terraformer.EncodingStore
+		cm *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		cm = &corev1.ConfigMap{Data: map[string]string{}}
+		s = &terraformer.EncodingStore{Underlying: &terraformer.ConfigMapStore{cm}}
+	})
+
+	Describe("#Object", func() {
+		It("should return the underlying ConfigMap", func() {
+			Expect(s.Object()).To(BeIdenticalTo(cm))
+		})
+	})
+
+	Describe("#Read", func() {
+		It("should return error for non-existing key", func() {
+			_, err := s.Read("non-existing")
+			Expect(err).To(MatchError(ContainSubstring("not found")))
+		})
+		It("should return value as is if object has no encoding annotation", func() {
+			cm.Data["foo"] = "bar"
+			reader, err := s.Read("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+		})
+		It("should decompress gzip encoded value", func() {
+			buf := &bytes.Buffer{}
+			w := gzip.NewWriter(buf)
+			_, err := w.Write([]byte("bar"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.Close()).To(Succeed())
+			cm.BinaryData = map[string][]byte{"foo": buf.Bytes()}
+			cm.Annotations = map[string]string{terraformer.AnnotationEncoding: terraformer.EncodingGzip}
+
+			reader, err := s.Read("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+		})
+		It("should fail for unsupported encoding", func() {
+			cm.Data["foo"] = "bar"
+			cm.Annotations = map[string]string{terraformer.AnnotationEncoding: "fancy"}
+			_, err := s.Read("foo")
+			Expect(err).To(MatchError(ContainSubstring("unsupported encoding")))
+		})
+	})
+
+	Describe("#Store", func() {
+		It("should store value as is and remove the encoding annotation", func() {
+			cm.Annotations = map[string]string{terraformer.AnnotationEncoding: terraformer.EncodingGzip}
+			Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("foo", "bar"))
+			Expect(cm.Annotations).NotTo(HaveKey(terraformer.AnnotationEncoding))
+		})
+		It("should store gzip compressed value", func() {
+			s.Encoding = terraformer.EncodingGzip
+			Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(Succeed())
+			Expect(cm.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncoding, terraformer.EncodingGzip))
+			Expect(cm.BinaryData).To(HaveKey("foo"))
+
+			reader, err := s.Read("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(gbytes.BufferReader(reader)).Should(gbytes.Say("^bar$"))
+		})
+		It("should fail for unsupported encoding", func() {
+			s.Encoding = "fancy"
+			Expect(s.Store("foo", bytes.NewBufferString("bar"))).To(MatchError(ContainSubstring("unsupported encoding")))
+		})
+	})
+})
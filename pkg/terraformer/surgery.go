@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultStateOperationsConfigMapKey is the default key of the state operations in the state operations ConfigMap.
+const DefaultStateOperationsConfigMapKey = "operations"
+
+// stateBackupSuffix is appended to the state file path for the backup, that terraform writes before modifying the
+// state with `terraform state rm` or `terraform state mv`.
+const stateBackupSuffix = ".backup"
+
+// fetchStateOperations collects and validates the operations of the given state command (StateRm or StateMv). For
+// StateRm, the operations are resource addresses, for StateMv they are `source=destination` pairs of addresses.
+// All operations are validated before the state is modified.
+func (t *Terraformer) fetchStateOperations(ctx context.Context, command Command) error {
+	operations, err := t.stateOperations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		return fmt.Errorf("no state operations given for terraform command %q", command)
+	}
+
+	if command == StateMv {
+		for _, operation := range operations {
+			if _, _, ok := splitAddressPair(operation); !ok {
+				return fmt.Errorf("state operation is not of the form source=destination: %q", operation)
+			}
+		}
+	}
+	t.operations = operations
+	return nil
+}
+
+// backupStateBeforeOperations records the state in the state history before it is modified by the given state
+// command. It has to be called before the state update worker is started, which stores the state concurrently.
+func (t *Terraformer) backupStateBeforeOperations(ctx context.Context, command Command) error {
+	if err := t.storeStateHistory(ctx, "backup-before-"+commandLabel(command)); err != nil {
+		return fmt.Errorf("failed to record state before modifying it: %w", err)
+	}
+	return nil
+}
+
+// runStateOperations executes the given state command (StateRm or StateMv) for all fetched state operations.
+// Terraform writes a backup of the state file in addition to the state revision recorded before. The modified state is
+// stored like for all other commands.
+func (t *Terraformer) runStateOperations(ctx context.Context, command Command) error {
+	log := t.stepLogger("runStateOperations")
+
+	if command == StateRm {
+		log.Info("removing resources from state", "addresses", t.operations)
+		if err := t.executeTerraform(ctx, StateRm, t.operations...); err != nil {
+			return fmt.Errorf("error executing terraform %s: %w", StateRm, err)
+		}
+		return nil
+	}
+
+	for _, operation := range t.operations {
+		source, destination, _ := splitAddressPair(operation)
+		log.Info("moving resource in state", "source", source, "destination", destination)
+		if err := t.executeTerraform(ctx, StateMv, source, destination); err != nil {
+			return fmt.Errorf("error executing terraform %s %s %s: %w", StateMv, source, destination, err)
+		}
+	}
+	return nil
+}
+
+// stateOperations returns the state operations given in the config, followed by the ones in the state operations
+// ConfigMap, which holds one operation per line. Empty lines and lines starting with `#` are ignored.
+func (t *Terraformer) stateOperations(ctx context.Context) ([]string, error) {
+	operations := append([]string(nil), t.config.StateOperations...)
+	if len(t.config.StateOperationsConfigMapName) == 0 {
+		return operations, nil
+	}
+
+	key := client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.StateOperationsConfigMapName}
+	configMap := &corev1.ConfigMap{}
+	if err := t.client.Get(ctx, key, configMap); err != nil {
+		return nil, fmt.Errorf("failed to fetch state operations: %w", err)
+	}
+
+	dataKey := t.config.StateOperationsConfigMapKey
+	if len(dataKey) == 0 {
+		dataKey = DefaultStateOperationsConfigMapKey
+	}
+
+	data, ok := configMap.Data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in ConfigMap %q", dataKey, key)
+	}
+
+	lines, err := parseLines(data)
+	return append(operations, lines...), err
+}
+
+// parseLines returns the trimmed lines of the given data, ignoring empty lines and lines starting with `#`.
+func parseLines(data string) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// backupArgs returns the arguments for writing a backup of the local state file. Terraform doesn't support backups
+// for the http backend.
+func (t *Terraformer) backupArgs() []string {
+	if t.config.HTTPBackend {
+		return nil
+	}
+	return []string{"-backup=" + t.paths.StatePath + stateBackupSuffix}
+}
+
+// commandLabel returns the given command in a form, that can be used as a label value.
+func commandLabel(command Command) string {
+	return strings.ReplaceAll(string(command), " ", "-")
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationTargetedOperation is the annotation on the state object, that records the last targeted apply or
+	// destroy, which only operated on a subset of the resources. It is removed by the next successful full apply.
+	AnnotationTargetedOperation = "terraformer.gardener.cloud/targeted-operation"
+
+	// TargetConfigMapTargetsKey is the key of the target addresses in the target ConfigMap.
+	TargetConfigMapTargetsKey = "targets"
+	// TargetConfigMapReplaceKey is the key of the addresses to replace in the target ConfigMap.
+	TargetConfigMapReplaceKey = "replace"
+)
+
+// TargetedOperation is the value of the AnnotationTargetedOperation annotation.
+type TargetedOperation struct {
+	// Command is the terraform command, that was executed with targets.
+	Command Command `json:"command"`
+	// Targets are the addresses of the targeted resources.
+	Targets []string `json:"targets"`
+	// Time is the time the targeted operation was started at.
+	Time metav1.Time `json:"time"`
+}
+
+// fetchTargets collects the target and replace addresses from the config and the target ConfigMap, which holds one
+// address per line in its targets and replace keys. Empty lines and lines starting with `#` are ignored.
+func (t *Terraformer) fetchTargets(ctx context.Context) error {
+	t.targets = append([]string(nil), t.config.Targets...)
+	t.replace = append([]string(nil), t.config.Replace...)
+
+	if len(t.config.TargetConfigMapName) > 0 {
+		key := client.ObjectKey{Namespace: t.config.Namespace, Name: t.config.TargetConfigMapName}
+		configMap := &corev1.ConfigMap{}
+		if err := t.client.Get(ctx, key, configMap); err != nil {
+			return fmt.Errorf("failed to fetch targets: %w", err)
+		}
+
+		targets, err := parseLines(configMap.Data[TargetConfigMapTargetsKey])
+		if err != nil {
+			return err
+		}
+		replace, err := parseLines(configMap.Data[TargetConfigMapReplaceKey])
+		if err != nil {
+			return err
+		}
+		t.targets = append(t.targets, targets...)
+		t.replace = append(t.replace, replace...)
+	}
+
+	if len(t.replace) > 0 && t.command == Destroy {
+		return fmt.Errorf("resources can't be replaced by terraform command %q", Destroy)
+	}
+	if (len(t.targets) > 0 || len(t.replace) > 0) && t.applyingPlan() {
+		return fmt.Errorf("targets can't be changed when applying a stored plan")
+	}
+	return nil
+}
+
+// targetArgs returns the -target and -replace arguments for the given command.
+func (t *Terraformer) targetArgs(command Command) []string {
+	if command != t.command {
+		return nil
+	}
+
+	var args []string
+	for _, target := range t.targets {
+		args = append(args, "-target="+target)
+	}
+	for _, replace := range t.replace {
+		args = append(args, "-replace="+replace)
+	}
+	return args
+}
+
+// recordTargetedOperation records the targeted operation in the AnnotationTargetedOperation annotation of the state
+// object, so that operators can see that only a subset of the resources was applied or destroyed. If there are no
+// targets, it removes the annotation instead, which is used after a successful full apply.
+func (t *Terraformer) recordTargetedOperation(ctx context.Context) error {
+	log := t.stepLogger("recordTargetedOperation")
+
+	// don't store the state in the meantime, so that the resourceVersion of the stored state can be updated afterwards
+	t.stateMutex.Lock()
+	defer t.stateMutex.Unlock()
+
+	obj := t.newStateObject(t.config.StateConfigMapName).Object()
+	if err := t.client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	if len(t.targets) == 0 {
+		if _, ok := annotations[AnnotationTargetedOperation]; !ok {
+			return nil
+		}
+		log.Info("removing targeted operation from state object after full apply")
+		delete(annotations, AnnotationTargetedOperation)
+	} else {
+		value, err := json.Marshal(TargetedOperation{Command: t.command, Targets: t.targets, Time: metav1.NewTime(t.clock.Now())})
+		if err != nil {
+			return err
+		}
+
+		log.Info("recording targeted operation in state object", "command", t.command, "targets", t.targets)
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AnnotationTargetedOperation] = string(value)
+	}
+	obj.SetAnnotations(annotations)
+
+	resourceVersion := obj.GetResourceVersion()
+	if err := t.client.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+	t.updateStoredResourceVersion(resourceVersion, obj)
+	return nil
+}
@@ -7,7 +7,6 @@ package terraformer
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -35,13 +34,6 @@ import (
 const (
 	// maxPatchRetries define the maximum number of attempts to patch a resource in case of conflict
 	maxPatchRetries = 2
-
-	// terraformVersionKey is the terraform version key in the terraform state JSON
-	terraformVersionKey = "terraform_version"
-	// registry is the terraform registry
-	registry = "registry.terraform.io"
-	// version012Prefix is the prefix for 0.12 versions
-	version012Prefix = "0.12."
 )
 
 var (
@@ -58,21 +50,6 @@ var (
 	SignalNotify = signal.Notify
 )
 
-var (
-	// providers is a map from the short provider names (used with terraform 0.12 or lower)
-	// to their sources (used with terraform 0.13 or higher)
-	providers = map[string]string{
-		"aws":         "hashicorp/aws",
-		"azurerm":     "hashicorp/azurerm",
-		"google":      "hashicorp/google",
-		"google-beta": "hashicorp/google-beta",
-		"openstack":   "terraform-provider-openstack/openstack",
-		"alicloud":    "hashicorp/alicloud",
-		"template":    "hashicorp/template",
-		"null":        "hashicorp/null",
-	}
-)
-
 // NewDefaultTerraformer creates a new Terraformer with the default PathSet and logger.
 func NewDefaultTerraformer(config *Config) (*Terraformer, error) {
 	return NewTerraformer(config, runtimelog.Log, DefaultPaths().WithBaseDir(config.BaseDir), clock.RealClock{})
@@ -89,6 +66,7 @@ func NewTerraformer(config *Config, log logr.Logger, paths *PathSet, clock clock
 		StateUpdateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(10*time.Millisecond, 5*time.Minute), "state-update"),
 		// use buffered channel, to make sure we don't miss the signal
 		FinalStateUpdateSucceeded: make(chan struct{}, 1),
+		finalStateUpdateFailed:    make(chan error, 1),
 	}
 
 	c, err := client.New(config.RESTConfig, client.Options{})
@@ -111,8 +89,25 @@ func (t *Terraformer) Run(command Command) error {
 		return fmt.Errorf("terraform command %q is not supported", command)
 	}
 
+	if command == Plan && len(t.config.PlanSecretName) == 0 {
+		return fmt.Errorf("terraform command %q requires a plan secret name", command)
+	}
+	if command == Drift && len(t.config.DriftReportConfigMapName) == 0 {
+		return fmt.Errorf("terraform command %q requires a drift report configmap name", command)
+	}
+	if command == Import && len(t.config.ImportConfigMapName) == 0 {
+		return fmt.Errorf("terraform command %q requires an import configmap name", command)
+	}
+	if command != Apply && command != Destroy && (len(t.config.Targets) > 0 || len(t.config.Replace) > 0 || len(t.config.TargetConfigMapName) > 0) {
+		return fmt.Errorf("terraform command %q doesn't support targets, only %q and %q do", command, Apply, Destroy)
+	}
+	if (command == StateRm || command == StateMv) && t.config.StateHistoryLimit <= 0 {
+		return fmt.Errorf("terraform command %q requires the state history to record the state before modifying it", command)
+	}
+
 	t.log.V(1).Info("executing terraformer with config", "config", t.config)
 
+	t.command = command
 	return t.execute(command)
 }
 
@@ -134,6 +129,16 @@ func (t *Terraformer) execute(command Command) (rErr error) {
 		}
 	}()
 
+	// make sure, no other terraformer operates on the same state, the lease is released after the final state update
+	releaseLease, err := t.AcquireLease(ctx, func() {
+		t.log.Info("lease was lost, stopping terraform")
+		cancel()
+	})
+	if err != nil {
+		return err
+	}
+	defer releaseLease()
+
 	if command == Destroy {
 		// Sometimes a state is empty because the Terraformer has never run successfully.
 		// Hence, we take a shortcut here and just remove the finalizer.
@@ -155,6 +160,22 @@ func (t *Terraformer) execute(command Command) (rErr error) {
 		return err
 	}
 
+	if command == Apply || command == Destroy {
+		if err := t.fetchTargets(ctx); err != nil {
+			return err
+		}
+	}
+
+	if command == StateRm || command == StateMv {
+		if err := t.fetchStateOperations(ctx, command); err != nil {
+			return err
+		}
+		// record the state before the state update worker starts storing it
+		if err := t.backupStateBeforeOperations(ctx, command); err != nil {
+			return err
+		}
+	}
+
 	shutdownWorker := t.StartStateUpdateWorker()
 	defer shutdownWorker()
 
@@ -167,15 +188,26 @@ func (t *Terraformer) execute(command Command) (rErr error) {
 		}
 	}()
 
-	// start file watcher for state file, that will continuously update state configmap
-	// as soon as state file changes on disk
-	shutdownFileWatcher, err := t.StartFileWatcher()
-	if err != nil {
-		return fmt.Errorf("failed to start state file watcher: %w", err)
-	}
+	if t.config.HTTPBackend {
+		// start http backend, that terraform will directly store the state in
+		shutdownBackend, err := t.StartHTTPBackend()
+		if err != nil {
+			return fmt.Errorf("failed to start http backend: %w", err)
+		}
 
-	// stop file watcher and wait for it to be finished
-	defer shutdownFileWatcher()
+		// stop http backend before the final state update
+		defer shutdownBackend()
+	} else {
+		// start file watcher for state file, that will continuously update state configmap
+		// as soon as state file changes on disk
+		shutdownFileWatcher, err := t.StartFileWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to start state file watcher: %w", err)
+		}
+
+		// stop file watcher and wait for it to be finished
+		defer shutdownFileWatcher()
+	}
 
 	if err := t.addFinalizer(ctx); err != nil {
 		return fmt.Errorf("error adding finalizers: %w", err)
@@ -185,24 +217,67 @@ func (t *Terraformer) execute(command Command) (rErr error) {
 	if err := t.executeTerraform(ctx, Init); err != nil {
 		return fmt.Errorf("error executing terraform %s: %w", Init, err)
 	}
+	if err := t.storeLockFile(ctx); err != nil {
+		return fmt.Errorf("failed to store provider lock file: %w", err)
+	}
 
-	// get terraform version from state and execute state replace-provider commands if needed
-	terraformVersion, err := t.getTerraformVersionFromState(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting terraform version from state: %w", err)
+	// replace providers in the state, that moved to a different source
+	if err := t.migrateProviders(ctx); err != nil {
+		return err
 	}
-	if strings.HasPrefix(terraformVersion, version012Prefix) {
-		for name, source := range providers {
-			fromProvider, toProvider := fmt.Sprintf("%s/-/%s", registry, name), fmt.Sprintf("%s/%s", registry, source)
-			if err := t.executeTerraform(ctx, StateReplaceProvider, fromProvider, toProvider); err != nil {
-				return fmt.Errorf("error executing terraform %s %s %s: %w", StateReplaceProvider, fromProvider, toProvider, err)
-			}
+
+	// record targeted operations before executing them, so that they are visible even if terraform fails
+	if len(t.targets) > 0 {
+		if err := t.recordTargetedOperation(ctx); err != nil {
+			return fmt.Errorf("failed to record targeted operation: %w", err)
 		}
 	}
 
 	// execute main terraform command
-	if err := t.executeTerraform(ctx, command); err != nil {
-		return fmt.Errorf("error executing terraform %s: %w", command, err)
+	switch {
+	case command == Plan:
+		if err := t.plan(ctx); err != nil {
+			return err
+		}
+	case command == Drift:
+		if err := t.detectDrift(ctx); err != nil {
+			return err
+		}
+	case command == Refresh:
+		// refresh-only applies only update the state to match the real infrastructure, they don't change any resources
+		if err := t.executeTerraform(ctx, Apply, "-refresh-only"); err != nil {
+			return fmt.Errorf("error executing terraform %s -refresh-only: %w", Apply, err)
+		}
+	case command == Import:
+		if err := t.importResources(ctx); err != nil {
+			return err
+		}
+	case command == StateRm || command == StateMv:
+		if err := t.runStateOperations(ctx, command); err != nil {
+			return err
+		}
+	case t.applyingPlan():
+		if err := t.applyPlan(ctx); err != nil {
+			return err
+		}
+	default:
+		if err := t.executeTerraform(ctx, command); err != nil {
+			return fmt.Errorf("error executing terraform %s: %w", command, err)
+		}
+	}
+
+	// a successful full apply makes up for previous targeted operations
+	if command == Apply && len(t.targets) == 0 {
+		if err := t.recordTargetedOperation(ctx); err != nil {
+			return fmt.Errorf("failed to record targeted operation: %w", err)
+		}
+	}
+
+	// export outputs after a successful apply, refreshing the state might change the outputs as well
+	if command == Apply || command == Refresh {
+		if err := t.exportOutputs(ctx); err != nil {
+			return fmt.Errorf("failed to export outputs: %w", err)
+		}
 	}
 
 	if command == Validate {
@@ -211,8 +286,9 @@ func (t *Terraformer) execute(command Command) (rErr error) {
 		}
 	}
 
-	// after a successful execution of destroy command, remove the finalizers from the resources
-	if command == Destroy {
+	// after a successful execution of destroy command, remove the finalizers from the resources, a targeted destroy
+	// leaves the other resources in the state though
+	if command == Destroy && len(t.targets) == 0 {
 		if err := t.removeFinalizer(); err != nil {
 			return fmt.Errorf("error removing finalizers: %w", err)
 		}
@@ -234,10 +310,14 @@ func (t *Terraformer) executeTerraform(ctx context.Context, command Command, par
 
 	var args []string
 	if command == StateReplaceProvider {
+		if t.config.HTTPBackend {
+			// the backend is configured in the config dir
+			args = append(args, "-chdir="+t.paths.ConfigDir)
+		}
 		args = append(args, strings.Split(string(command), " ")...)
 	} else {
 		args = append(args, "-chdir="+t.paths.ConfigDir)
-		args = append(args, string(command))
+		args = append(args, strings.Split(string(command), " ")...)
 	}
 
 	// disable colors, which will look weird in termination message, k8s status fields and so on
@@ -245,19 +325,52 @@ func (t *Terraformer) executeTerraform(ctx context.Context, command Command, par
 
 	switch command {
 	case Init:
+		args = append(args, t.lockFileArgs()...)
+		args = append(args, t.runArgs(command)...)
 	case Plan:
-		args = append(args, "-var-file="+t.paths.VarsPath, "-parallelism=4", "-detailed-exitcode", "-state="+t.paths.StatePath)
+		args = append(args, t.varFileArgs()...)
+		args = append(args, t.runArgs(command)...)
+		args = append(args, "-detailed-exitcode")
+		args = append(args, t.stateArgs()...)
+		args = append(args, params...)
 	case Apply:
-		args = append(args, "-var-file="+t.paths.VarsPath, "-parallelism=4", "-auto-approve", "-state="+t.paths.StatePath)
+		// variables can't be set when applying a stored plan, they are part of the plan
+		if !t.applyingPlan() {
+			args = append(args, t.varFileArgs()...)
+		}
+		args = append(args, t.runArgs(command)...)
+		args = append(args, "-auto-approve")
+		args = append(args, t.stateArgs()...)
+		args = append(args, t.targetArgs(command)...)
+		args = append(args, params...)
 	case Destroy:
-		args = append(args, "-var-file="+t.paths.VarsPath, "-parallelism=4", "-auto-approve", "-state="+t.paths.StatePath)
+		args = append(args, t.varFileArgs()...)
+		args = append(args, t.runArgs(command)...)
+		args = append(args, "-auto-approve")
+		args = append(args, t.stateArgs()...)
+		args = append(args, t.targetArgs(command)...)
+	case Import:
+		args = append(args, t.varFileArgs()...)
+		args = append(args, "-input=false")
+		args = append(args, t.stateArgs()...)
+		args = append(args, t.runArgs(command)...)
+		args = append(args, params...)
+	case StateRm, StateMv:
+		args = append(args, t.stateArgs()...)
+		args = append(args, t.backupArgs()...)
+		args = append(args, t.runArgs(command)...)
+		args = append(args, params...)
 	case StateReplaceProvider:
-		args = append(args, "-auto-approve", "-state="+t.paths.StatePath)
+		args = append(args, "-auto-approve")
+		args = append(args, t.stateArgs()...)
 		args = append(args, params...)
 	}
 
 	log.Info("executing terraform", "command", command, "args", strings.Join(args, " "))
 	tfCmd := exec.Command(TerraformBinary, args...) // #nosec: G204 -- the variable is only referring to subcommands of the hardcoded executable. Since the full command had to be constructed dynamically, this is needed.
+	if len(t.varEnv) > 0 {
+		tfCmd.Env = append(os.Environ(), t.varEnv...)
+	}
 
 	logBuffer := &bytes.Buffer{}
 	terraformOutput := io.MultiWriter(Stderr, logBuffer)
@@ -292,6 +405,13 @@ func (t *Terraformer) executeTerraform(ctx context.Context, command Command, par
 	}()
 
 	if err := tfCmd.Wait(); err != nil {
+		// for the plan and drift commands, pending changes are no failure, validate still fails on pending changes though
+		if command == Plan && (t.command == Plan || t.command == Drift) && tfCmd.ProcessState.ExitCode() == exitCodePendingChanges {
+			log.Info("terraform process finished successfully with pending changes", "command", command)
+			t.pendingChanges = true
+			return nil
+		}
+
 		log.Error(err, "terraform process finished with error", "command", command)
 
 		// copy terraform logs to termination log file for error code detection
@@ -307,6 +427,38 @@ func (t *Terraformer) executeTerraform(ctx context.Context, command Command, par
 	return nil
 }
 
+// captureTerraform executes the given terraform command and returns its output. In contrast to executeTerraform, the
+// output is not logged, as it might contain sensitive values.
+func (t *Terraformer) captureTerraform(ctx context.Context, log logr.Logger, command Command, params ...string) ([]byte, error) {
+	args := []string{"-chdir=" + t.paths.ConfigDir}
+	args = append(args, strings.Split(string(command), " ")...)
+	args = append(args, "-no-color")
+	args = append(args, params...)
+	log.Info("executing terraform", "command", command, "args", strings.Join(args, " "))
+
+	stdout := &bytes.Buffer{}
+	tfCmd := exec.CommandContext(ctx, TerraformBinary, args...) // #nosec: G204 -- the variable is only referring to subcommands of the hardcoded executable.
+	tfCmd.Stdout = stdout
+	tfCmd.Stderr = Stderr
+
+	if err := tfCmd.Run(); err != nil {
+		if tfCmd.ProcessState != nil {
+			return nil, utils.WithExitCode{Code: tfCmd.ProcessState.ExitCode(), Underlying: err}
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// stateArgs returns the arguments for using the local state file. The state file is not used with the http backend,
+// where terraform reads and writes the state via terraformer instead.
+func (t *Terraformer) stateArgs() []string {
+	if t.config.HTTPBackend {
+		return nil
+	}
+	return []string{"-state=" + t.paths.StatePath}
+}
+
 func (t *Terraformer) addFinalizer(ctx context.Context) error {
 	logger := t.stepLogger("add-finalizer")
 	return t.updateObjects(ctx, logger, controllerutil.AddFinalizer)
@@ -324,46 +476,15 @@ func (t *Terraformer) removeFinalizer() error {
 }
 
 func (t *Terraformer) isStateEmpty(ctx context.Context) (bool, error) {
-	state := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      t.config.StateConfigMapName,
-			Namespace: t.config.Namespace,
-		},
-	}
-	if err := t.client.Get(ctx, client.ObjectKeyFromObject(state), state); client.IgnoreNotFound(err) != nil {
+	state, err := t.readState(ctx, t.config.StateConfigMapName)
+	if err != nil {
 		return false, err
 	}
-	data, ok := state.Data[tfStateKey]
-	return !ok || len(data) == 0, nil
-}
-
-func (t *Terraformer) getTerraformVersionFromState(ctx context.Context) (string, error) {
-	state := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      t.config.StateConfigMapName,
-			Namespace: t.config.Namespace,
-		},
-	}
-	if err := t.client.Get(ctx, client.ObjectKeyFromObject(state), state); client.IgnoreNotFound(err) != nil {
-		return "", err
-	}
-	data, ok := state.Data[tfStateKey]
-	if !ok || len(data) == 0 {
-		return "", nil
-	}
-	var terraformState map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &terraformState); err != nil {
-		return "", fmt.Errorf("could not unmarshal terraform state from JSON: %w", err)
-	}
-	terraformVersion, ok := terraformState[terraformVersionKey].(string)
-	if !ok {
-		return "", fmt.Errorf("terraform state key %s is not of type string", terraformVersionKey)
-	}
-	return terraformVersion, nil
+	return len(state) == 0, nil
 }
 
 func (t *Terraformer) terraformObjects() []client.Object {
-	return []client.Object{
+	objects := []client.Object{
 		&corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: t.config.Namespace,
@@ -376,13 +497,52 @@ func (t *Terraformer) terraformObjects() []client.Object {
 				Name:      t.config.ConfigurationConfigMapName,
 			},
 		},
-		&corev1.ConfigMap{
+		t.newStateObject(t.config.StateConfigMapName).Object(),
+	}
+
+	for _, source := range t.config.VariablesSources {
+		meta := metav1.ObjectMeta{Namespace: t.config.Namespace, Name: source.Name}
+		if source.Kind == VariablesSourceConfigMap {
+			objects = append(objects, &corev1.ConfigMap{ObjectMeta: meta})
+		} else {
+			objects = append(objects, &corev1.Secret{ObjectMeta: meta})
+		}
+	}
+
+	for _, name := range t.config.ExtraConfigurationConfigMapNames {
+		objects = append(objects, &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: t.config.Namespace,
-				Name:      t.config.StateConfigMapName,
+				Name:      name,
 			},
-		},
+		})
 	}
+
+	if len(t.config.OutputsConfigMapName) > 0 {
+		objects = append(objects, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: t.config.Namespace,
+				Name:      t.config.OutputsConfigMapName,
+			},
+		})
+	}
+	if len(t.config.OutputsSecretName) > 0 {
+		objects = append(objects, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: t.config.Namespace,
+				Name:      t.config.OutputsSecretName,
+			},
+		})
+	}
+	if len(t.config.ProviderLockConfigMapName) > 0 {
+		objects = append(objects, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: t.config.Namespace,
+				Name:      t.config.ProviderLockConfigMapName,
+			},
+		})
+	}
+	return objects
 }
 
 func (t *Terraformer) updateObjects(ctx context.Context, log logr.Logger, patchObj func(client.Object, string) bool) error {
@@ -418,7 +578,13 @@ func (t *Terraformer) updateObjectFinalizers(ctx context.Context, log logr.Logge
 			if apierrors.IsNotFound(err) {
 				log.V(1).Info("create empty object", "key", key)
 				patchObj(obj, TerraformerFinalizer)
-				return t.client.Create(ctx, obj)
+				if err := t.client.Create(ctx, obj); err != nil {
+					return err
+				}
+				t.stateMutex.Lock()
+				t.updateStoredResourceVersion("", obj)
+				t.stateMutex.Unlock()
+				return nil
 			}
 			log.Error(err, "failed to get object", "key", key)
 			return err
@@ -427,6 +593,11 @@ func (t *Terraformer) updateObjectFinalizers(ctx context.Context, log logr.Logge
 		old := (obj.DeepCopyObject()).(client.Object)
 		patchObj(obj, TerraformerFinalizer)
 		err = t.client.Patch(ctx, obj, client.MergeFromWithOptions(old, client.MergeFromWithOptimisticLock{}))
+		if err == nil {
+			t.stateMutex.Lock()
+			t.updateStoredResourceVersion(old.GetResourceVersion(), obj)
+			t.stateMutex.Unlock()
+		}
 		if !apierrors.IsConflict(err) {
 			break
 		}
@@ -5,6 +5,8 @@
 package terraformer_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -18,7 +20,10 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/gardener/terraformer/pkg/terraformer"
@@ -26,6 +31,44 @@ import (
 	testutils "github.com/gardener/terraformer/test/utils"
 )
 
+const (
+	planSecretName           = "tf-plan"
+	outputsConfigMapName     = "tf-outputs"
+	outputsSecretName        = "tf-sensitive-outputs"
+	driftReportConfigMapName = "tf-drift"
+	importConfigMapName      = "tf-imports"
+)
+
+// newTestTerraformer returns a Terraformer for the given test objects, that configures the objects of all optional
+// features (plan Secret, outputs ConfigMap and Secret, drift report ConfigMap and import ConfigMap). The config can be
+// modified further with mutateConfig.
+func newTestTerraformer(testObjs *testutils.TestObjects, paths *terraformer.PathSet, logWriter io.Writer, mutateConfig ...func(*terraformer.Config)) *terraformer.Terraformer {
+	config := &terraformer.Config{
+		Namespace:                  testObjs.Namespace,
+		ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+		StateConfigMapName:         testObjs.StateConfigMap.Name,
+		VariablesSecretName:        testObjs.VariablesSecret.Name,
+		PlanSecretName:             planSecretName,
+		OutputsConfigMapName:       outputsConfigMapName,
+		OutputsSecretName:          outputsSecretName,
+		DriftReportConfigMapName:   driftReportConfigMapName,
+		ImportConfigMapName:        importConfigMapName,
+		RESTConfig:                 restConfig,
+	}
+	for _, mutate := range mutateConfig {
+		mutate(config)
+	}
+
+	tf, err := terraformer.NewTerraformer(
+		config,
+		zap.New(zap.UseDevMode(true), zap.WriteTo(logWriter)),
+		paths,
+		clock.RealClock{},
+	)
+	Expect(err).NotTo(HaveOccurred())
+	return tf
+}
+
 var _ = Describe("Terraformer", func() {
 	Describe("#NewDefaultTerraformer", func() {
 		It("should fail, if it can't create a client", func() {
@@ -95,8 +138,14 @@ var _ = Describe("Terraformer", func() {
 			It("should not allow to run Init directly", func() {
 				Expect(tf.Run(terraformer.Init)).To(MatchError(ContainSubstring("not supported")))
 			})
-			It("should not allow to run Plan directly", func() {
-				Expect(tf.Run(terraformer.Plan)).To(MatchError(ContainSubstring("not supported")))
+			It("should not allow to run Plan without a plan secret name", func() {
+				Expect(tf.Run(terraformer.Plan)).To(MatchError(ContainSubstring("requires a plan secret name")))
+			})
+			It("should not allow to run Drift without a drift report configmap name", func() {
+				Expect(tf.Run(terraformer.Drift)).To(MatchError(ContainSubstring("requires a drift report configmap name")))
+			})
+			It("should not allow to run Import without an import configmap name", func() {
+				Expect(tf.Run(terraformer.Import)).To(MatchError(ContainSubstring("requires an import configmap name")))
 			})
 			It("should fail if config can't be fetched", func() {
 				Expect(testClient.Delete(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
@@ -182,6 +231,41 @@ var _ = Describe("Terraformer", func() {
 				Expect(testObjs.VariablesSecret.Finalizers).ToNot(ContainElement(terraformer.TerraformerFinalizer))
 				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
 			})
+			It("should run Refresh successfully", func() {
+				Expect(tf.Run(terraformer.Refresh)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("init"))
+				Eventually(logBuffer).Should(gbytes.Say("apply -no-color -var-file=\\S+ -parallelism=4 -auto-approve -state=" + paths.StatePath + " -refresh-only"))
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+				testObjs.Refresh()
+				Expect(testObjs.StateConfigMap.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+			It("should pass the configured run options to terraform", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.Parallelism = 20
+					config.LockTimeout = time.Minute
+					config.SkipRefresh = true
+					config.ExtraArgs = map[terraformer.Command][]string{
+						terraformer.Init:  {"-upgrade"},
+						terraformer.Apply: {"-compact-warnings"},
+					}
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("init -no-color -upgrade"))
+				Eventually(logBuffer).Should(gbytes.Say("apply -no-color -var-file=\\S+ -parallelism=20 -lock-timeout=1m0s -refresh=false -compact-warnings -auto-approve -state=\\S+"))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+			It("should not skip refreshing the state on Refresh", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.SkipRefresh = true
+				})
+
+				Expect(tf.Run(terraformer.Refresh)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("apply -no-color -var-file=\\S+ -parallelism=4 -auto-approve -state=\\S+ -refresh-only"))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("-refresh=false"))
+			})
 			It("should create non-existing objects successfully on Apply", func() {
 				Expect(testClient.Delete(ctx, testObjs.StateConfigMap)).To(Succeed())
 				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(testObjs.StateConfigMap), testObjs.StateConfigMap)).ToNot(Succeed())
@@ -199,6 +283,668 @@ var _ = Describe("Terraformer", func() {
 				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
 				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
 			})
+			It("should run Plan successfully and store the plan", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter)
+
+				Expect(tf.Run(terraformer.Plan)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("init"))
+				Eventually(logBuffer).Should(gbytes.Say("plan"))
+				Eventually(logBuffer).Should(gbytes.Say("show"))
+				Eventually(logBuffer).Should(gbytes.Say("successfully stored plan"))
+
+				secret := &corev1.Secret{}
+				Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: planSecretName}, secret)).To(Succeed())
+				Expect(secret.Labels).To(HaveKeyWithValue(terraformer.LabelPlanOf, testObjs.StateConfigMap.Name))
+				Expect(secret.Annotations).To(HaveKeyWithValue(terraformer.AnnotationPlanPendingChanges, "false"))
+				Expect(secret.Annotations).To(HaveKeyWithValue(terraformer.AnnotationPlanStateSerial, "0"))
+				Expect(secret.Annotations).To(HaveKeyWithValue(terraformer.AnnotationPlanStateLineage, ""))
+				Expect(secret.Data).To(HaveKeyWithValue("terraform.tfplan", []byte("fake plan")))
+				Expect(secret.Data).To(HaveKeyWithValue("terraform.tfplan.json", ContainSubstring("format_version")))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+
+			It("should run Drift successfully and store the drift report", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter)
+
+				Expect(tf.Run(terraformer.Drift)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("plan -no-color .* -refresh-only"))
+				Eventually(logBuffer).Should(gbytes.Say("no drift detected"))
+
+				configMap := &corev1.ConfigMap{}
+				Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: driftReportConfigMapName}, configMap)).To(Succeed())
+				report := &terraformer.DriftReport{}
+				Expect(json.Unmarshal([]byte(configMap.Data[terraformer.DriftReportKey]), report)).To(Succeed())
+				Expect(report.DriftDetected).To(BeFalse())
+				Expect(report.Resources).To(BeEmpty())
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+
+			Context("stored plan", func() {
+				BeforeEach(func() {
+					Expect(newTestTerraformer(testObjs, paths, multiWriter).Run(terraformer.Plan)).To(Succeed())
+					Expect(os.Remove(paths.PlanPath)).To(Succeed())
+					tf = newTestTerraformer(testObjs, paths, multiWriter)
+				})
+
+				It("should apply the stored plan", func() {
+					Expect(tf.Run(terraformer.Apply)).To(Succeed())
+					Eventually(logBuffer).Should(gbytes.Say("apply -no-color -parallelism=4 -auto-approve -state=" + paths.StatePath + " " + paths.PlanPath))
+					Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+					Expect(paths.PlanPath).To(testutils.BeFileWithContents(Equal("fake plan")))
+					Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+				})
+				It("should export the outputs", func() {
+					Expect(tf.Run(terraformer.Apply)).To(Succeed())
+					Eventually(logBuffer).Should(gbytes.Say("successfully exported outputs"))
+
+					configMap := &corev1.ConfigMap{}
+					Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: outputsConfigMapName}, configMap)).To(Succeed())
+					Expect(configMap.Data).To(Equal(map[string]string{
+						"vpc_id":  `{"type":"string","value":"vpc-1234"}`,
+						"subnets": `{"type":["list","string"],"value":["subnet-1","subnet-2"]}`,
+					}))
+					Expect(configMap.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+
+					secret := &corev1.Secret{}
+					Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: outputsSecretName}, secret)).To(Succeed())
+					Expect(secret.Data).To(Equal(map[string][]byte{
+						"password": []byte(`{"type":"string","value":"secret"}`),
+					}))
+					Expect(secret.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+					Expect(logBuffer.Contents()).NotTo(ContainSubstring("vpc-1234"))
+				})
+				It("should reject the stored plan if the state changed", func() {
+					testObjs.Refresh()
+					testObjs.StateConfigMap.Data[testutils.StateKey] = `{"terraform_version":"","serial":1}`
+					Expect(testClient.Update(ctx, testObjs.StateConfigMap)).To(Succeed())
+
+					err := tf.Run(terraformer.Apply)
+					Expect(err).To(MatchError(ContainSubstring(`plan was created against state serial "0", but the current state serial is "1"`)))
+
+					var exitCoder utils.ExitCoder
+					Expect(errors.As(err, &exitCoder)).To(BeTrue())
+					Expect(exitCoder.ExitCode()).To(Equal(terraformer.ExitCodeStalePlan))
+					Expect(paths.PlanPath).NotTo(BeAnExistingFile())
+				})
+				It("should reject the stored plan if the state lineage changed", func() {
+					testObjs.Refresh()
+					testObjs.StateConfigMap.Data[testutils.StateKey] = `{"terraform_version":"","serial":0,"lineage":"other"}`
+					Expect(testClient.Update(ctx, testObjs.StateConfigMap)).To(Succeed())
+
+					err := tf.Run(terraformer.Apply)
+					Expect(err).To(MatchError(ContainSubstring(`plan was created against state lineage "", but the current state lineage is "other"`)))
+
+					var exitCoder utils.ExitCoder
+					Expect(errors.As(err, &exitCoder)).To(BeTrue())
+					Expect(exitCoder.ExitCode()).To(Equal(terraformer.ExitCodeStalePlan))
+				})
+				It("should reject the stored plan if the config changed", func() {
+					testObjs.Refresh()
+					testObjs.ConfigurationConfigMap.Data[testutils.ConfigMainKey] += "\n# changed"
+					Expect(testClient.Update(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
+
+					err := tf.Run(terraformer.Apply)
+					Expect(err).To(MatchError(ContainSubstring("config changed since the plan was created")))
+
+					var exitCoder utils.ExitCoder
+					Expect(errors.As(err, &exitCoder)).To(BeTrue())
+					Expect(exitCoder.ExitCode()).To(Equal(terraformer.ExitCodeStalePlan))
+				})
+				It("should encrypt the stored plan and apply it", func() {
+					keys := &terraformer.EncryptionKeys{ActiveKeyID: "key-1", Keys: map[string][]byte{"key-1": bytes.Repeat([]byte{1}, 32)}}
+					withEncryption := func(config *terraformer.Config) {
+						config.StateEncryptionKeys = keys
+					}
+					Expect(newTestTerraformer(testObjs, paths, multiWriter, withEncryption).Run(terraformer.Plan)).To(Succeed())
+					Expect(os.Remove(paths.PlanPath)).To(Succeed())
+
+					secret := &corev1.Secret{}
+					Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: planSecretName}, secret)).To(Succeed())
+					Expect(secret.Annotations).To(HaveKeyWithValue(terraformer.AnnotationEncryptionKeyID, "key-1"))
+					Expect(secret.Data["terraform.tfplan"]).NotTo(ContainSubstring("fake plan"))
+					Expect(secret.Data["terraform.tfplan.json"]).NotTo(ContainSubstring("format_version"))
+
+					Expect(newTestTerraformer(testObjs, paths, multiWriter, withEncryption).Run(terraformer.Apply)).To(Succeed())
+					Expect(paths.PlanPath).To(testutils.BeFileWithContents(Equal("fake plan")))
+				})
+			})
+		})
+
+		Context("drift detected", func() {
+			var (
+				resetBinary func()
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCodeForCommands(
+						"init", "0",
+						"plan", "2",
+					),
+					testutils.OverwriteSleepDuration("50ms"),
+					testutils.OverwriteDriftedResources("aws_vpc.main", "aws_subnet.a"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should store the drift report and return the drift exit code", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter)
+
+				err := tf.Run(terraformer.Drift)
+				Expect(err).To(MatchError(ContainSubstring("detected drift of resources")))
+
+				var exitCoder utils.ExitCoder
+				Expect(errors.As(err, &exitCoder)).To(BeTrue())
+				Expect(exitCoder.ExitCode()).To(Equal(terraformer.ExitCodeDrift))
+
+				configMap := &corev1.ConfigMap{}
+				Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: driftReportConfigMapName}, configMap)).To(Succeed())
+				report := &terraformer.DriftReport{}
+				Expect(json.Unmarshal([]byte(configMap.Data[terraformer.DriftReportKey]), report)).To(Succeed())
+				Expect(report.DriftDetected).To(BeTrue())
+				Expect(report.Resources).To(ConsistOf(
+					terraformer.DriftedResource{Address: "aws_vpc.main", Type: "aws_vpc", Actions: []string{"update"}, Attributes: []string{"tags"}},
+					terraformer.DriftedResource{Address: "aws_subnet.a", Type: "aws_subnet", Actions: []string{"update"}, Attributes: []string{"tags"}},
+				))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+		})
+
+		Context("import", func() {
+			var (
+				resetBinary        func()
+				overwriteExitCodes testutils.Overwrite
+			)
+
+			BeforeEach(func() {
+				overwriteExitCodes = testutils.OverwriteExitCode("0")
+
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: importConfigMapName},
+					Data: map[string]string{
+						terraformer.DefaultImportConfigMapKey: `# existing resources
+aws_vpc.main=vpc-1234
+aws_subnet.a["zone=a"] = subnet-1234
+`,
+					},
+				})).To(Succeed())
+			})
+
+			JustBeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					overwriteExitCodes,
+					testutils.OverwriteSleepDuration("50ms"),
+					testutils.OverwriteStateResources("aws_vpc.main"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should import resources, that are not in the state yet", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter)
+
+				Expect(tf.Run(terraformer.Import)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("state list"))
+				Eventually(logBuffer).Should(gbytes.Say("resource is already in state, skipping import"))
+				Eventually(logBuffer).Should(gbytes.Say(`import -no-color -var-file=\S+ -input=false -state=\S+ aws_subnet.a\[.+zone=a.+\] subnet-1234`))
+				Eventually(logBuffer).Should(gbytes.Say("successfully imported resource"))
+				Eventually(logBuffer).Should(gbytes.Say("finished importing resources"))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+
+			Context("import fails", func() {
+				BeforeEach(func() {
+					overwriteExitCodes = testutils.OverwriteExitCodeForCommands(
+						"init", "0",
+						"state", "0",
+						"import", "1",
+					)
+				})
+
+				It("should report the failed imports", func() {
+					tf = newTestTerraformer(testObjs, paths, multiWriter)
+
+					err := tf.Run(terraformer.Import)
+					Expect(err).To(MatchError(ContainSubstring("failed to import resources")))
+					Expect(err).To(MatchError(ContainSubstring(`aws_subnet.a["zone=a"]: terraform command failed with exit code 1`)))
+					Eventually(logBuffer).Should(gbytes.Say("failed to import resource"))
+				})
+			})
+		})
+
+		Context("state operations", func() {
+			var (
+				resetBinary func()
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should fail if the state history is disabled", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.StateOperations = []string{"aws_vpc.main"}
+				})
+				Expect(tf.Run(terraformer.StateRm)).To(MatchError(ContainSubstring("requires the state history")))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("state rm"))
+			})
+			It("should fail if no state operations are given", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.StateHistoryLimit = 1
+				})
+				Expect(tf.Run(terraformer.StateRm)).To(MatchError(ContainSubstring("no state operations given")))
+			})
+			It("should remove the given resources from the state", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.StateHistoryLimit = 1
+					config.StateOperations = []string{"aws_vpc.main", "aws_subnet.a"}
+				})
+
+				Expect(tf.Run(terraformer.StateRm)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("state rm -no-color -state=" + paths.StatePath + " -backup=" + paths.StatePath + ".backup aws_vpc.main aws_subnet.a"))
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+			It("should move the resources given in the ConfigMap", func() {
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-state-operations"},
+					Data: map[string]string{
+						terraformer.DefaultStateOperationsConfigMapKey: `# refactoring
+aws_vpc.main=module.network.aws_vpc.main
+`,
+					},
+				})).To(Succeed())
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.StateHistoryLimit = 1
+					config.StateOperations = []string{"aws_subnet.a=module.network.aws_subnet.a"}
+					config.StateOperationsConfigMapName = "tf-state-operations"
+				})
+
+				Expect(tf.Run(terraformer.StateMv)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("state mv -no-color -state=\\S+ -backup=\\S+ aws_subnet.a module.network.aws_subnet.a"))
+				Eventually(logBuffer).Should(gbytes.Say("state mv -no-color -state=\\S+ -backup=\\S+ aws_vpc.main module.network.aws_vpc.main"))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+			It("should not move any resource if an operation is invalid", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.StateHistoryLimit = 1
+					config.StateOperations = []string{"aws_subnet.a=module.network.aws_subnet.a", "aws_vpc.main"}
+				})
+
+				Expect(tf.Run(terraformer.StateMv)).To(MatchError(ContainSubstring(`not of the form source=destination: "aws_vpc.main"`)))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("state mv"))
+			})
+		})
+
+		Context("variables sources", func() {
+			var (
+				resetBinary func()
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should pass the variables sources as var files and environment variables", func() {
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-tuning"},
+					Data: map[string]string{
+						"tuning.tfvars.json": `{"SOME_VAR": "tuned"}`,
+						"README.md":          "not a var file",
+					},
+				})).To(Succeed())
+				Expect(testClient.Create(ctx, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-credentials"},
+					Data:       map[string][]byte{"credentials.tfvars": []byte(`ACCESS_KEY = "secret"`)},
+				})).To(Succeed())
+				Expect(testClient.Create(ctx, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-env"},
+					Data:       map[string][]byte{"region": []byte("eu-west-1")},
+				})).To(Succeed())
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.VariablesSources = []terraformer.VariablesSource{
+						{Kind: terraformer.VariablesSourceConfigMap, Name: "tf-tuning"},
+						{Kind: terraformer.VariablesSourceSecret, Name: "tf-credentials"},
+						{Kind: terraformer.VariablesSourceSecret, Name: "tf-env", Env: true},
+					}
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("apply -no-color -var-file=" + paths.VarsPath + " -var-file=\\S+/source-0-tuning.tfvars.json -var-file=\\S+/source-1-credentials.tfvars -parallelism=4"))
+				Eventually(logBuffer).Should(gbytes.Say("variable from env: TF_VAR_region"))
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("eu-west-1"))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("source-0-README.md"))
+
+				testObjs.Refresh()
+				secret := &corev1.Secret{}
+				Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: "tf-credentials"}, secret)).To(Succeed())
+				Expect(secret.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+			})
+			It("should fail if a variables source is not present", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.VariablesSources = []terraformer.VariablesSource{{Kind: terraformer.VariablesSourceSecret, Name: "tf-credentials"}}
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(MatchError(ContainSubstring("not found")))
+			})
+		})
+
+		Context("provider lock file", func() {
+			var (
+				resetBinary func()
+				lockKey     client.ObjectKey
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+
+				lockKey = client.ObjectKey{Namespace: testObjs.Namespace, Name: "tf-provider-lock"}
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should store the lock file after init and restore it on later runs", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.ProviderLockConfigMapName = lockKey.Name
+				})
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+
+				configMap := &corev1.ConfigMap{}
+				Expect(testClient.Get(ctx, lockKey, configMap)).To(Succeed())
+				Expect(configMap.Data).To(HaveKeyWithValue(".terraform.lock.hcl", ContainSubstring(`provider "registry.terraform.io/hashicorp/aws"`)))
+				Expect(configMap.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+
+				By("restoring the stored lock file")
+				configMap.Data[".terraform.lock.hcl"] = "# recorded lock file\n"
+				Expect(testClient.Update(ctx, configMap)).To(Succeed())
+				resourceVersion := configMap.ResourceVersion
+
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.ProviderLockConfigMapName = lockKey.Name
+				})
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Expect(paths.LockFilePath).To(testutils.BeFileWithContents(Equal("# recorded lock file\n")))
+
+				Expect(testClient.Get(ctx, lockKey, configMap)).To(Succeed())
+				Expect(configMap.ResourceVersion).To(Equal(resourceVersion))
+			})
+			It("should init with a read-only lock file in strict mode", func() {
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: lockKey.Namespace, Name: lockKey.Name},
+					Data:       map[string]string{".terraform.lock.hcl": "# recorded lock file\n"},
+				})).To(Succeed())
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.ProviderLockConfigMapName = lockKey.Name
+					config.StrictProviderLock = true
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("init -no-color -lockfile=readonly"))
+				Expect(paths.LockFilePath).To(testutils.BeFileWithContents(Equal("# recorded lock file\n")))
+			})
+			It("should fail in strict mode if no lock file is stored", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.ProviderLockConfigMapName = lockKey.Name
+					config.StrictProviderLock = true
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(MatchError(ContainSubstring("strict provider lock requires .terraform.lock.hcl")))
+			})
+		})
+
+		Context("provider migrations", func() {
+			var (
+				resetBinary func()
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should replace the providers in the state with the configured migrations", func() {
+				testObjs.StateConfigMap.Data[testutils.StateKey] = `{"terraform_version":"0.15.5","resources":[` +
+					`{"mode":"managed","type":"example_vpc","name":"main","provider":"provider[\"registry.terraform.io/example/example\"]"},` +
+					`{"mode":"managed","type":"null_resource","name":"foo","provider":"module.foo.provider[\"registry.terraform.io/hashicorp/null\"].bar"}]}`
+				Expect(testClient.Update(ctx, testObjs.StateConfigMap)).To(Succeed())
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-provider-migrations"},
+					Data: map[string]string{terraformer.ProviderMigrationConfigMapKey: `# moved to the new namespace
+example/example=registry.example.com/example-org/example
+`},
+				})).To(Succeed())
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.ProviderMigrations = map[string]string{"registry.terraform.io/-/aws": "hashicorp/aws"}
+					config.ProviderMigrationConfigMapName = "tf-provider-migrations"
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("state replace-provider .* registry.terraform.io/example/example registry.example.com/example-org/example"))
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("registry.terraform.io/hashicorp/null registry"))
+			})
+			It("should not replace providers, that are not in the state", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("state replace-provider"))
+			})
+			It("should fail if the provider migration ConfigMap is not present", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.ProviderMigrationConfigMapName = "tf-provider-migrations"
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(MatchError(ContainSubstring("failed to fetch provider migrations")))
+			})
+		})
+
+		Context("targeted operations", func() {
+			var (
+				resetBinary func()
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should apply the given targets and record the targeted operation", func() {
+				Expect(testClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testObjs.Namespace, Name: "tf-targets"},
+					Data: map[string]string{
+						terraformer.TargetConfigMapTargetsKey: "# network only\naws_subnet.a\n",
+						terraformer.TargetConfigMapReplaceKey: "aws_instance.broken",
+					},
+				})).To(Succeed())
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+					config.Targets = []string{"aws_vpc.main"}
+					config.TargetConfigMapName = "tf-targets"
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("apply -no-color -var-file=\\S+ -parallelism=4 -auto-approve -state=\\S+ -target=aws_vpc.main -target=aws_subnet.a -replace=aws_instance.broken"))
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
+
+				testObjs.Refresh()
+				Expect(testObjs.StateConfigMap.Annotations).To(HaveKey(terraformer.AnnotationTargetedOperation))
+				operation := &terraformer.TargetedOperation{}
+				Expect(json.Unmarshal([]byte(testObjs.StateConfigMap.Annotations[terraformer.AnnotationTargetedOperation]), operation)).To(Succeed())
+				Expect(operation.Command).To(Equal(terraformer.Apply))
+				Expect(operation.Targets).To(ConsistOf("aws_vpc.main", "aws_subnet.a"))
+
+				By("storing the state without conflicts after patching the state object")
+				Expect(testObjs.StateConfigMap.Annotations).To(HaveKey(terraformer.AnnotationConfigSHA256))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("state object was modified concurrently"))
+			})
+			It("should remove the targeted operation after a full apply", func() {
+				testObjs.StateConfigMap.Annotations = map[string]string{terraformer.AnnotationTargetedOperation: `{"command":"destroy"}`}
+				Expect(testClient.Update(ctx, testObjs.StateConfigMap)).To(Succeed())
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.PlanSecretName = ""
+				})
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("-target="))
+
+				testObjs.Refresh()
+				Expect(testObjs.StateConfigMap.Annotations).NotTo(HaveKey(terraformer.AnnotationTargetedOperation))
+			})
+			It("should keep the finalizers after a targeted destroy", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.Targets = []string{"aws_instance.broken"}
+				})
+
+				Expect(tf.Run(terraformer.Destroy)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("destroy -no-color -var-file=\\S+ -parallelism=4 -auto-approve -state=\\S+ -target=aws_instance.broken"))
+
+				testObjs.Refresh()
+				Expect(testObjs.StateConfigMap.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+				Expect(testObjs.StateConfigMap.Annotations).To(HaveKey(terraformer.AnnotationTargetedOperation))
+			})
+			It("should fail if resources should be replaced by destroy", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.Replace = []string{"aws_instance.broken"}
+				})
+
+				Expect(tf.Run(terraformer.Destroy)).To(MatchError(ContainSubstring("can't be replaced")))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("-replace="))
+			})
+			It("should fail if targets are given for other commands than apply and destroy", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.Targets = []string{"aws_vpc.main"}
+				})
+				Expect(tf.Run(terraformer.Plan)).To(MatchError(ContainSubstring("doesn't support targets")))
+
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.Replace = []string{"aws_vpc.main"}
+				})
+				Expect(tf.Run(terraformer.Refresh)).To(MatchError(ContainSubstring("doesn't support targets")))
+
+				tf = newTestTerraformer(testObjs, paths, multiWriter, func(config *terraformer.Config) {
+					config.TargetConfigMapName = "tf-targets"
+				})
+				Expect(tf.Run(terraformer.Validate)).To(MatchError(ContainSubstring("doesn't support targets")))
+				Expect(logBuffer.Contents()).NotTo(ContainSubstring("executing terraform"))
+			})
+		})
+
+		Context("plan with pending changes", func() {
+			var (
+				resetBinary func()
+			)
+
+			BeforeEach(func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCodeForCommands(
+						"init", "0",
+						"plan", "2",
+					),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should run Plan successfully and record the pending changes", func() {
+				tf = newTestTerraformer(testObjs, paths, multiWriter)
+
+				Expect(tf.Run(terraformer.Plan)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully with pending changes"))
+
+				secret := &corev1.Secret{}
+				Expect(testClient.Get(ctx, client.ObjectKey{Namespace: testObjs.Namespace, Name: planSecretName}, secret)).To(Succeed())
+				Expect(secret.Annotations).To(HaveKeyWithValue(terraformer.AnnotationPlanPendingChanges, "true"))
+				Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
+			})
+			It("should still fail Validate on pending changes", func() {
+				err := tf.Run(terraformer.Validate)
+				Expect(err).To(MatchError(ContainSubstring("terraform command failed")))
+
+				var withExitCode utils.WithExitCode
+				Expect(errors.As(err, &withExitCode)).To(BeTrue())
+				Expect(withExitCode.ExitCode()).To(Equal(2))
+			})
 		})
 
 		Context("failed terraform execution", func() {
@@ -351,6 +1097,8 @@ var _ = Describe("Terraformer", func() {
 				var err error
 
 				testObjs = testutils.PrepareTestObjects(ctx, testClient, "", "0.12.31")
+				testObjs.StateConfigMap.Data[testutils.StateKey] = `{"terraform_version":"0.12.31","resources":[{"mode":"managed","type":"aws_vpc","name":"main","provider":"provider.aws"}]}`
+				Expect(testClient.Update(ctx, testObjs.StateConfigMap)).To(Succeed())
 
 				tf, err = terraformer.NewTerraformer(
 					&terraformer.Config{
@@ -390,7 +1138,7 @@ var _ = Describe("Terraformer", func() {
 				It("should run Apply successfully and execute the state replace-provider command", func() {
 					Expect(tf.Run(terraformer.Apply)).To(Succeed())
 					Eventually(logBuffer).Should(gbytes.Say("some terraform output"))
-					Eventually(logBuffer).Should(gbytes.Say("state replace-provider"))
+					Eventually(logBuffer).Should(gbytes.Say("state replace-provider .* registry.terraform.io/-/aws registry.terraform.io/hashicorp/aws"))
 					Eventually(logBuffer).Should(gbytes.Say("terraform process finished successfully"))
 					testObjs.Refresh()
 					Expect(paths.TerminationMessagePath).To(testutils.BeEmptyFile())
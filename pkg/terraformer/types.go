@@ -5,6 +5,10 @@
 package terraformer
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/go-logr/logr"
 	"go.uber.org/zap/zapcore"
 	"k8s.io/client-go/rest"
@@ -28,6 +32,22 @@ const (
 	Validate Command = "validate"
 	// Plan is the terraform `plan` command.
 	Plan Command = "plan"
+	// Show is the terraform `show` command.
+	Show Command = "show"
+	// Output is the terraform `output` command.
+	Output Command = "output"
+	// Import is the terraform `import` command.
+	Import Command = "import"
+	// StateList is the terraform `state` command with the `list` subcommand.
+	StateList Command = "state list"
+	// StateRm is the terraform `state` command with the `rm` subcommand.
+	StateRm Command = "state rm"
+	// StateMv is the terraform `state` command with the `mv` subcommand.
+	StateMv Command = "state mv"
+	// Drift is not a terraform command, it detects drift with a refresh-only `terraform plan`.
+	Drift Command = "drift"
+	// Refresh is not a terraform command, it updates the state with a refresh-only `terraform apply`.
+	Refresh Command = "refresh"
 	// StateReplaceProvider is the terraform `state` command with the `replace-provider` subcommand.
 	StateReplaceProvider Command = "state replace-provider"
 )
@@ -37,11 +57,27 @@ const (
 	TerraformerFinalizer = "gardener.cloud/terraformer"
 )
 
+// StateKind is the kind of object the state is stored in.
+type StateKind string
+
+const (
+	// StateKindConfigMap stores the state in a ConfigMap.
+	StateKindConfigMap StateKind = "ConfigMap"
+	// StateKindSecret stores the state in a Secret.
+	StateKindSecret StateKind = "Secret"
+)
+
 // SupportedCommands contains the set of supported terraform commands, that can be run as `terraformer <command>`.
 var SupportedCommands = map[Command]struct{}{
 	Apply:    {},
 	Destroy:  {},
 	Validate: {},
+	Plan:     {},
+	Drift:    {},
+	Refresh:  {},
+	Import:   {},
+	StateRm:  {},
+	StateMv:  {},
 }
 
 // Terraformer can execute terraform commands and fetch/store config and state from/into Secrets/ConfigMaps
@@ -61,16 +97,63 @@ type Terraformer struct {
 
 	// clock allows faking some time operations in tests
 	clock clock.Clock
+
+	// command is the terraform command executed by Run. It is recorded in the state history.
+	command Command
+
+	// stateMutex serializes storing the state and guards stateChunked and storedState, as the state is stored by the
+	// state update worker, the http backend and the main goroutine.
+	stateMutex sync.Mutex
+	// stateChunked records whether the stored state is split into chunks, so that the chunks can be cleaned up once the
+	// state fits into a single object again.
+	stateChunked bool
+	// leaseRenewTime is the time of the last renewal of the held lease in unix nanoseconds, it is zero if no lease is
+	// held. leaseLost records whether the lease was lost, in which case storing the state is refused.
+	leaseRenewTime atomic.Int64
+	leaseLost      atomic.Bool
+	// pendingChanges records whether the plan created by the plan command contains changes.
+	pendingChanges bool
+	// targets and replace are the addresses of the resources, that apply or destroy are limited to and that apply
+	// forces to be replaced. They are collected from the config and the target ConfigMap.
+	targets, replace []string
+	// operations are the validated operations of the state rm and state mv commands.
+	operations []string
+	// configFiles are the paths of the fetched config files relative to the config dir.
+	configFiles []string
+	// varFiles are the var files of the variables sources and varEnv are the `TF_VAR_` environment variables of the
+	// variables sources in form `name=value`.
+	varFiles, varEnv []string
+	// configSHA256 is the checksum of the fetched config files and variables, which is recorded on the state object.
+	configSHA256 string
+	// lockFile is the stored provider dependency lock file, which was restored into the config dir.
+	lockFile []byte
+	// storedState is the revision of the state, that was fetched or stored last. It is nil if the state wasn't fetched.
+	storedState *storedState
+	// stateUpdatesPerformed and stateUpdatesSkipped count the performed and skipped (unchanged) state updates.
+	stateUpdatesPerformed, stateUpdatesSkipped int
+	// finalStateUpdateFailed is a channel over which the state update worker signals, that the final state update
+	// failed permanently, i.e. retrying it doesn't help.
+	finalStateUpdateFailed chan error
 }
 
 // Config holds configuration options for Terraformer.
 type Config struct {
-	// ConfigurationConfigMapName is the name of the ConfigMap that holds the `main.tf` and `variables.tf` files.
+	// ConfigurationConfigMapName is the name of the ConfigMap that holds the `main.tf` and `variables.tf` files. All
+	// other keys are written to the config dir as well, see ConfigPathSeparator for storing files in subdirectories.
 	ConfigurationConfigMapName string
-	// StateConfigMapName is the name of the ConfigMap that the `terraform.tfstate` file should be stored in.
+	// ExtraConfigurationConfigMapNames are the names of additional ConfigMaps, whose keys are written to the config dir
+	// like the keys of the configuration ConfigMap, e.g. for sharing modules between configurations.
+	ExtraConfigurationConfigMapNames []string
+	// StateConfigMapName is the name of the object that the `terraform.tfstate` file should be stored in. Depending on
+	// StateKind, this is either a ConfigMap or a Secret.
 	StateConfigMapName string
+	// StateKind is the kind of the object that the state is stored in (defaults to StateKindConfigMap).
+	StateKind StateKind
 	// VariablesSecretName is the name of the Secret that holds the `terraform.tfvars` file.
 	VariablesSecretName string
+	// VariablesSources are additional sources of variables. Their var files are passed after the `terraform.tfvars`
+	// file in the given order, so that later sources take precedence over earlier ones.
+	VariablesSources []VariablesSource
 	// Namespace is the namespace to store the configuration resources in.
 	Namespace string
 
@@ -82,13 +165,191 @@ type Config struct {
 
 	// BaseDir is the base directory to be used for all terraform files (defaults to '/').
 	BaseDir string
+
+	// StateChunkSize is the maximum size of a state in bytes, that is stored in a single object. Larger states are split
+	// across multiple chunk objects (defaults to DefaultStateChunkSize).
+	StateChunkSize int
+	// LeaseDuration is the duration of the Lease, that terraformer holds while running. If zero, no Lease is acquired.
+	LeaseDuration time.Duration
+	// LeaseWaitTimeout is the maximum duration to wait for a Lease held by another terraformer to be released.
+	LeaseWaitTimeout time.Duration
+	// LeasePolicy defines how stale Leases of other terraformers are handled (defaults to LeasePolicySteal).
+	LeasePolicy LeasePolicy
+
+	// StateUpdateDebounce is the duration, that the state file has to be unchanged before a continuous state update is
+	// triggered, so that bursts of state file writes are collapsed into a single update. If zero, every write of the
+	// state file triggers a state update. The final state update is never delayed.
+	StateUpdateDebounce time.Duration
+	// StateUpdateMaxDelay is the maximum duration, that continuous state updates are delayed by StateUpdateDebounce
+	// after the first write of the state file. If zero, state updates are delayed until the state file doesn't change
+	// anymore.
+	StateUpdateMaxDelay time.Duration
+
+	// StateFilePollInterval is the interval for polling the state file for changes of its modification time and size.
+	// If zero, the state directory is watched with inotify instead.
+	StateFilePollInterval time.Duration
+
+	// StateHistoryLimit is the number of state revisions to keep in the state history. If zero, no state history is kept.
+	StateHistoryLimit int
+	// CompressState configures whether the state should be stored gzip compressed.
+	CompressState bool
+	// StateEncryptionKeys holds the keys for encrypting and decrypting the state. If nil or without an active key,
+	// the state is stored unencrypted.
+	StateEncryptionKeys *EncryptionKeys
+	// ForceStateUpdate allows overwriting stored states with states of a different lineage or a lower serial.
+	ForceStateUpdate bool
+
+	// PlanSecretName is the name of the Secret, that the plan file created by the plan command is stored in.
+	PlanSecretName string
+
+	// OutputsConfigMapName is the name of the ConfigMap, that the non-sensitive outputs are exported to after a
+	// successful apply or refresh. Non-sensitive outputs aren't exported if it is empty.
+	OutputsConfigMapName string
+	// OutputsSecretName is the name of the Secret, that the sensitive outputs are exported to after a successful apply
+	// or refresh. Sensitive outputs aren't exported if it is empty.
+	OutputsSecretName string
+
+	// DriftReportConfigMapName is the name of the ConfigMap, that the drift command stores the drift report in.
+	DriftReportConfigMapName string
+
+	// ImportConfigMapName is the name of the ConfigMap, that holds the list of resources to import for the import command.
+	ImportConfigMapName string
+	// ImportConfigMapKey is the key of the import list in the import ConfigMap, defaults to DefaultImportConfigMapKey.
+	ImportConfigMapKey string
+
+	// StateOperations are the operations for the state rm and state mv commands, i.e. resource addresses to remove
+	// or `source=destination` pairs of addresses to move.
+	StateOperations []string
+	// StateOperationsConfigMapName is the name of a ConfigMap, that holds additional state operations.
+	StateOperationsConfigMapName string
+	// StateOperationsConfigMapKey is the key of the state operations in the state operations ConfigMap, defaults to
+	// DefaultStateOperationsConfigMapKey.
+	StateOperationsConfigMapKey string
+
+	// Targets are the addresses of the resources, that apply and destroy are limited to.
+	Targets []string
+	// Replace are the addresses of the resources, that apply forces to be replaced.
+	Replace []string
+	// TargetConfigMapName is the name of a ConfigMap, that holds additional target and replace addresses in the
+	// TargetConfigMapTargetsKey and TargetConfigMapReplaceKey keys.
+	TargetConfigMapName string
+
+	// Parallelism is the number of concurrent operations of terraform plan, apply and destroy, defaults to
+	// DefaultParallelism.
+	Parallelism int
+	// LockTimeout is the duration terraform retries acquiring the state lock. If zero, terraform fails immediately if
+	// the state is locked.
+	LockTimeout time.Duration
+	// SkipRefresh configures terraform plan, apply and destroy to skip refreshing the state before planning.
+	SkipRefresh bool
+	// ExtraArgs are additional arguments per command, that are passed to terraform. Only the arguments in
+	// AllowedExtraArgs are allowed.
+	ExtraArgs map[Command][]string
+
+	// ProviderLockConfigMapName is the name of the ConfigMap, that the provider dependency lock file is stored in after
+	// terraform init and restored from before terraform init. If empty, the lock file is not persisted.
+	ProviderLockConfigMapName string
+	// StrictProviderLock configures terraform init to fail if the providers don't match the lock file instead of
+	// updating it. A lock file has to be stored or contained in the config.
+	StrictProviderLock bool
+	// ProviderMigrations maps provider sources to the sources, that they are replaced with in the state, in addition
+	// to DefaultProviderMigrations. Sources without hostname belong to the default registry.
+	ProviderMigrations map[string]string
+	// ProviderMigrationConfigMapName is the name of a ConfigMap holding additional provider migrations in the
+	// ProviderMigrationConfigMapKey, which take precedence over ProviderMigrations.
+	ProviderMigrationConfigMapName string
+
+	// HTTPBackend configures terraform to use the http backend served by terraformer, which reads and writes the state
+	// object directly, instead of a local state file, that is watched for changes.
+	HTTPBackend bool
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaler.
 func (c *Config) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("configurationConfigMapName", c.ConfigurationConfigMapName)
+	_ = enc.AddArray("extraConfigurationConfigMapNames", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, name := range c.ExtraConfigurationConfigMapNames {
+			arr.AppendString(name)
+		}
+		return nil
+	}))
 	enc.AddString("stateConfigMapName", c.StateConfigMapName)
+	enc.AddString("stateKind", string(c.StateKind))
 	enc.AddString("variablesSecretName", c.VariablesSecretName)
+	_ = enc.AddArray("variablesSources", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, source := range c.VariablesSources {
+			if source.Env {
+				arr.AppendString("env:" + source.String())
+				continue
+			}
+			arr.AppendString(source.String())
+		}
+		return nil
+	}))
 	enc.AddString("namespace", c.Namespace)
+	enc.AddInt("stateChunkSize", c.StateChunkSize)
+	enc.AddDuration("leaseDuration", c.LeaseDuration)
+	enc.AddDuration("leaseWaitTimeout", c.LeaseWaitTimeout)
+	enc.AddString("leasePolicy", string(c.LeasePolicy))
+	enc.AddDuration("stateUpdateDebounce", c.StateUpdateDebounce)
+	enc.AddDuration("stateUpdateMaxDelay", c.StateUpdateMaxDelay)
+	enc.AddDuration("stateFilePollInterval", c.StateFilePollInterval)
+	enc.AddInt("stateHistoryLimit", c.StateHistoryLimit)
+	enc.AddBool("compressState", c.CompressState)
+	if c.StateEncryptionKeys != nil {
+		enc.AddString("stateEncryptionKeyID", c.StateEncryptionKeys.ActiveKeyID)
+	}
+	enc.AddBool("forceStateUpdate", c.ForceStateUpdate)
+	enc.AddString("planSecretName", c.PlanSecretName)
+	enc.AddString("outputsConfigMapName", c.OutputsConfigMapName)
+	enc.AddString("outputsSecretName", c.OutputsSecretName)
+	enc.AddString("driftReportConfigMapName", c.DriftReportConfigMapName)
+	enc.AddString("importConfigMapName", c.ImportConfigMapName)
+	enc.AddString("importConfigMapKey", c.ImportConfigMapKey)
+	_ = enc.AddArray("stateOperations", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, operation := range c.StateOperations {
+			arr.AppendString(operation)
+		}
+		return nil
+	}))
+	enc.AddString("stateOperationsConfigMapName", c.StateOperationsConfigMapName)
+	enc.AddString("stateOperationsConfigMapKey", c.StateOperationsConfigMapKey)
+	_ = enc.AddArray("targets", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, target := range c.Targets {
+			arr.AppendString(target)
+		}
+		return nil
+	}))
+	_ = enc.AddArray("replace", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, replace := range c.Replace {
+			arr.AppendString(replace)
+		}
+		return nil
+	}))
+	enc.AddString("targetConfigMapName", c.TargetConfigMapName)
+	enc.AddInt("parallelism", c.Parallelism)
+	enc.AddDuration("lockTimeout", c.LockTimeout)
+	enc.AddBool("skipRefresh", c.SkipRefresh)
+	_ = enc.AddObject("extraArgs", zapcore.ObjectMarshalerFunc(func(obj zapcore.ObjectEncoder) error {
+		for command, args := range c.ExtraArgs {
+			_ = obj.AddArray(string(command), zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+				for _, arg := range args {
+					arr.AppendString(arg)
+				}
+				return nil
+			}))
+		}
+		return nil
+	}))
+	enc.AddString("providerLockConfigMapName", c.ProviderLockConfigMapName)
+	enc.AddBool("strictProviderLock", c.StrictProviderLock)
+	_ = enc.AddObject("providerMigrations", zapcore.ObjectMarshalerFunc(func(obj zapcore.ObjectEncoder) error {
+		for from, to := range c.ProviderMigrations {
+			obj.AddString(from, to)
+		}
+		return nil
+	}))
+	enc.AddString("providerMigrationConfigMapName", c.ProviderMigrationConfigMapName)
+	enc.AddBool("httpBackend", c.HTTPBackend)
 	return nil
 }
@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// VariablesSourceKind is the kind of object, that a VariablesSource is read from.
+type VariablesSourceKind string
+
+const (
+	// VariablesSourceSecret reads variables from a Secret.
+	VariablesSourceSecret VariablesSourceKind = "Secret"
+	// VariablesSourceConfigMap reads variables from a ConfigMap.
+	VariablesSourceConfigMap VariablesSourceKind = "ConfigMap"
+)
+
+// varEnvPrefix is the prefix of environment variables, that terraform reads variables from.
+const varEnvPrefix = "TF_VAR_"
+
+// varNameRegexp matches valid names of terraform variables.
+var varNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// VariablesSource is an additional source of terraform variables.
+type VariablesSource struct {
+	// Kind is the kind of the object holding the variables.
+	Kind VariablesSourceKind
+	// Name is the name of the object holding the variables.
+	Name string
+	// Env configures that every key of the object is passed as `TF_VAR_<key>` environment variable to terraform.
+	// Otherwise, every key ending with `.tfvars` or `.tfvars.json` is passed as var file.
+	Env bool
+}
+
+// String returns the kind and name of the variables source.
+func (s VariablesSource) String() string {
+	return string(s.Kind) + "/" + s.Name
+}
+
+// fetchVariablesSources writes the var files of all variables sources to the vars dir and collects the environment
+// variables of the sources, that are passed as environment variables.
+func (t *Terraformer) fetchVariablesSources(ctx context.Context, log logr.Logger) error {
+	t.varFiles, t.varEnv = nil, nil
+
+	for i, source := range t.config.VariablesSources {
+		key := client.ObjectKey{Namespace: t.config.Namespace, Name: source.Name}
+		log := log.WithValues("kind", source.Kind, "object", key)
+		log.V(1).Info("fetching variables source")
+
+		var (
+			obj  Store
+			keys []string
+		)
+		switch source.Kind {
+		case VariablesSourceSecret:
+			secret := &corev1.Secret{}
+			if err := t.client.Get(ctx, key, secret); err != nil {
+				return err
+			}
+			obj = &EncodingStore{Underlying: &SecretStore{secret}}
+			for dataKey := range secret.Data {
+				keys = append(keys, dataKey)
+			}
+		case VariablesSourceConfigMap:
+			configMap := &corev1.ConfigMap{}
+			if err := t.client.Get(ctx, key, configMap); err != nil {
+				return err
+			}
+			obj = &EncodingStore{Underlying: &ConfigMapStore{configMap}}
+			for dataKey := range configMap.Data {
+				keys = append(keys, dataKey)
+			}
+			for dataKey := range configMap.BinaryData {
+				keys = append(keys, dataKey)
+			}
+		default:
+			return fmt.Errorf("unsupported kind of variables source %q", source)
+		}
+		slices.Sort(keys)
+
+		for _, dataKey := range keys {
+			isVarFile := strings.HasSuffix(dataKey, ".tfvars") || strings.HasSuffix(dataKey, ".tfvars.json")
+			if !source.Env && !isVarFile {
+				log.Info("ignoring key of variables source, which is neither a .tfvars nor a .tfvars.json file", "dataKey", dataKey)
+				continue
+			}
+			if source.Env && !varNameRegexp.MatchString(dataKey) {
+				return fmt.Errorf("key %q of variables source %q is not a valid variable name", dataKey, source)
+			}
+
+			reader, err := obj.Read(dataKey)
+			if err != nil {
+				return fmt.Errorf("failed reading from variables source %q: %w", source, err)
+			}
+
+			if source.Env {
+				value, err := io.ReadAll(reader)
+				if err != nil {
+					return err
+				}
+				// don't log the value, it most likely contains credentials
+				log.V(1).Info("passing key as environment variable", "dataKey", dataKey, "env", varEnvPrefix+dataKey)
+				t.varEnv = append(t.varEnv, varEnvPrefix+dataKey+"="+string(value))
+				continue
+			}
+
+			// the index keeps the var files of different sources apart, the suffix tells terraform how to parse the file
+			filePath := filepath.Join(t.paths.VarsDir, fmt.Sprintf("source-%d-%s", i, dataKey))
+			log.V(1).Info("copying contents to file", "dataKey", dataKey, "file", filePath)
+			if err := writeFile(filePath, reader); err != nil {
+				return err
+			}
+			t.varFiles = append(t.varFiles, filePath)
+		}
+	}
+	return nil
+}
+
+// varFileArgs returns the -var-file arguments for the variables Secret followed by the var files of the variables
+// sources in the configured order, so that later var files take precedence.
+func (t *Terraformer) varFileArgs() []string {
+	args := []string{"-var-file=" + t.paths.VarsPath}
+	for _, file := range t.varFiles {
+		args = append(args, "-var-file="+file)
+	}
+	return args
+}
@@ -28,3 +28,31 @@ func (w WithExitCode) Error() string {
 func (w WithExitCode) Unwrap() error {
 	return w.Underlying
 }
+
+// ExitCoder is implemented by errors, that determine the exit code of terraformer.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// WithTerraformerExitCode annotates an error of terraformer itself (as opposed to a failed terraform command) with an
+// exit code, that is distinct from the exit codes of terraform.
+type WithTerraformerExitCode struct {
+	Code       int
+	Underlying error
+}
+
+// ExitCode returns the exit code associated with this error.
+func (w WithTerraformerExitCode) ExitCode() int {
+	return w.Code
+}
+
+// Error implements error.
+func (w WithTerraformerExitCode) Error() string {
+	return fmt.Sprintf("terraformer failed with exit code %d: %v", w.Code, w.Underlying)
+}
+
+// Unwrap returns the underlying error.
+func (w WithTerraformerExitCode) Unwrap() error {
+	return w.Underlying
+}
@@ -39,3 +39,18 @@ var _ = Describe("WithExitCode", func() {
 		Expect(withExitCode.Unwrap()).To(Equal(underlying))
 	})
 })
+
+var _ = Describe("WithTerraformerExitCode", func() {
+	It("should return specified exit code and unwrap underlying", func() {
+		underlying := errors.New("foo")
+		err := fmt.Errorf("wrapped: %w", utils.WithTerraformerExitCode{Code: 10, Underlying: underlying})
+
+		Expect(err).To(MatchError(ContainSubstring("terraformer failed with exit code 10")))
+		Expect(err).To(MatchError(ContainSubstring(underlying.Error())))
+
+		var exitCoder utils.ExitCoder
+		Expect(errors.As(err, &exitCoder)).To(BeTrue())
+		Expect(exitCoder.ExitCode()).To(Equal(10))
+		Expect(errors.Is(err, underlying)).To(BeTrue())
+	})
+})
@@ -14,4 +14,6 @@ const (
 	VarsKey = "terraform.tfvars"
 	// StateKey is the key for the terraform.tfstate file
 	StateKey = "terraform.tfstate"
+	// StateManifestKey is the key for the manifest of a chunked terraform.tfstate file
+	StateManifestKey = "terraform.tfstate.manifest"
 )
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -18,17 +20,64 @@ var (
 	// expectedExitCodes is a list of expected exit codes for the different commands in form `42` or `init=0,apply=42`.
 	expectedExitCodes string
 	sleepDuration     string
+	// stateResources is a list of resource addresses in form `aws_vpc.main,aws_subnet.a`, that `terraform state list`
+	// reports as resources in the state.
+	stateResources string
+	// driftedResources is a list of resource addresses in form `aws_vpc.main,aws_subnet.a`, that `terraform show`
+	// reports as drifted.
+	driftedResources string
 )
 
+// outputs is the output of `terraform output -json`.
+const outputs = `{
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-1234"},
+  "subnets": {"sensitive": false, "type": ["list", "string"], "value": ["subnet-1", "subnet-2"]},
+  "password": {"sensitive": true, "type": "string", "value": "secret"}
+}`
+
+// lockFile is the provider dependency lock file written by `terraform init`.
+const lockFile = `provider "registry.terraform.io/hashicorp/aws" {
+  version = "5.0.0"
+  hashes  = ["h1:fake"]
+}
+`
+
 // This packages contains a simple program which can be built in tests to mock terraform executions
 // It basically just writes some lines to stdout and stderr, sleeps for `sleepDuration` and exits with `exitCode`.
 func main() {
-	fmt.Println("some terraform output")
-	fmt.Println("args: " + strings.Join(os.Args[1:], " "))
-
 	command := getCommand(os.Args[1:])
 	exitCode := getExpectedExitCode(command)
 
+	// terraform output and show only write JSON to stdout, which is parsed by terraformer
+	switch command {
+	case "output":
+		if exitCode == 0 {
+			fmt.Println(outputs)
+		}
+		os.Exit(exitCode)
+	case "state":
+		if getSubcommand(os.Args[1:]) == "list" {
+			if exitCode == 0 && stateResources != "" {
+				fmt.Println(strings.ReplaceAll(stateResources, ",", "\n"))
+			}
+			os.Exit(exitCode)
+		}
+	case "show":
+		if exitCode == 0 {
+			fmt.Println(getPlan())
+		}
+		os.Exit(exitCode)
+	}
+
+	fmt.Println("some terraform output")
+	fmt.Println("args: " + strings.Join(os.Args[1:], " "))
+	// only print the names of variables passed via environment, their values might be sensitive
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, "TF_VAR_") {
+			fmt.Println("variable from env: " + name)
+		}
+	}
+
 	if sleepDuration != "" && command != "" && command != "init" && command != "state" {
 		done := make(chan struct{})
 		defer close(done)
@@ -55,6 +104,25 @@ func main() {
 		time.Sleep(duration)
 	}
 
+	// write a fake plan file, if terraform plan is asked to save the plan
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-out=") {
+			if err := os.WriteFile(strings.TrimPrefix(arg, "-out="), []byte("fake plan"), 0600); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	// write a fake lock file like terraform init does, unless it is asked not to update the lock file
+	if command == "init" && exitCode == 0 && strings.HasPrefix(os.Args[1], "-chdir=") {
+		lockFilePath := filepath.Join(strings.TrimPrefix(os.Args[1], "-chdir="), ".terraform.lock.hcl")
+		if _, err := os.Stat(lockFilePath); os.IsNotExist(err) && !slices.Contains(os.Args[1:], "-lockfile=readonly") {
+			if err := os.WriteFile(lockFilePath, []byte(lockFile), 0600); err != nil {
+				panic(err)
+			}
+		}
+	}
+
 	fmt.Println("finished terraform execution")
 	_, _ = fmt.Fprintln(os.Stderr, "some terraform error")
 
@@ -71,6 +139,16 @@ func getCommand(args []string) string {
 	return args[0]
 }
 
+func getSubcommand(args []string) string {
+	if strings.HasPrefix(args[0], "-chdir=") {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return ""
+	}
+	return args[1]
+}
+
 func getExpectedExitCode(command string) int {
 	if expectedExitCodes == "" {
 		return 0
@@ -99,3 +177,17 @@ func getExpectedExitCode(command string) int {
 	}
 	return 0
 }
+
+func getPlan() string {
+	var resourceDrift []string
+	if driftedResources != "" {
+		for _, address := range strings.Split(driftedResources, ",") {
+			resourceType := strings.Split(address, ".")[0]
+			resourceDrift = append(resourceDrift, fmt.Sprintf(
+				`{"address": %q, "type": %q, "change": {"actions": ["update"], "before": {"id": "1", "tags": {}}, "after": {"id": "1", "tags": {"foo": "bar"}}}}`,
+				address, resourceType,
+			))
+		}
+	}
+	return `{"format_version": "1.2", "resource_drift": [` + strings.Join(resourceDrift, ",") + `]}`
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -89,6 +90,24 @@ func OverwriteSleepDuration(code string) Overwrite {
 	}
 }
 
+// OverwriteStateResources returns an overwrite that configures the binary to report the given resource addresses in
+// `terraform state list`.
+func OverwriteStateResources(addresses ...string) Overwrite {
+	return Overwrite{
+		VarPath: "main.stateResources",
+		Value:   strings.Join(addresses, ","),
+	}
+}
+
+// OverwriteDriftedResources returns an overwrite that configures the binary to report the given resource addresses
+// as drifted in `terraform show`.
+func OverwriteDriftedResources(addresses ...string) Overwrite {
+	return Overwrite{
+		VarPath: "main.driftedResources",
+		Value:   strings.Join(addresses, ","),
+	}
+}
+
 // HashBuildArgs returns a hash for an arbitrary set of build args.
 // This allows us to detect, if a test binary really needs to be rebuild or if we can reuse the same binary from the
 // last build. This way we can significantly shorten the runtime of the binary e2e tests, which build terraformer for